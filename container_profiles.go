@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// ContainerProfile defines resource configurations merged over the ones of
+// the pod for the containers with the given names. Names are exact names or
+// glob patterns
+type ContainerProfile struct {
+	Names []string `json:"names"`
+	ResourceOverrides
+}
+
+// matchesName returns true when the container name is equal to one of the
+// names, or matches one of the glob patterns, of the profile
+func (p *ContainerProfile) matchesName(name string) bool {
+	for _, pattern := range p.Names {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ContainerProfile) valid() error {
+	if len(p.Names) == 0 {
+		return fmt.Errorf("at least one container name must be defined")
+	}
+	for _, pattern := range p.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid container name pattern '%s': %w", pattern, err)
+		}
+	}
+	if p.Qos != nil {
+		// The QoS class is computed for the whole pod
+		return fmt.Errorf("the QoS configuration cannot be defined by container profiles")
+	}
+	return p.ResourceOverrides.valid()
+}
+
+// validContainerProfiles checks the container profiles, and the settings
+// resulting from merging each one of them over all the settings that can be
+// used for a container: the pod settings, with or without an image profile
+func (s *Settings) validContainerProfiles() error {
+	if len(s.ContainerProfiles) == 0 {
+		return nil
+	}
+	candidates := s.podSettingsCandidates()
+	for _, settings := range s.podSettingsCandidates() {
+		for i := range s.ImageProfiles {
+			candidates = append(candidates, settings.withOverrides(&s.ImageProfiles[i].ResourceOverrides))
+		}
+	}
+	for i := range s.ContainerProfiles {
+		containerProfile := &s.ContainerProfiles[i]
+		err := containerProfile.valid()
+		for _, settings := range candidates {
+			if err != nil {
+				break
+			}
+			err = settings.withOverrides(&containerProfile.ResourceOverrides).Valid()
+		}
+		if err != nil {
+			return errors.Join(fmt.Errorf("invalid containerProfiles[%d] settings", i), err)
+		}
+	}
+	return nil
+}
+
+// forContainerName returns the settings used for the container with the
+// given name. The first container profile matching the name is merged over
+// the settings. The settings are returned unchanged when no profile matches
+func (s *Settings) forContainerName(name string) *Settings {
+	for i := range s.ContainerProfiles {
+		if s.ContainerProfiles[i].matchesName(name) {
+			settings := s.withOverrides(&s.ContainerProfiles[i].ResourceOverrides)
+			settings.ContainerProfiles = nil
+			return settings
+		}
+	}
+	return s
+}
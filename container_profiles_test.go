@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+func TestContainerProfiles(t *testing.T) {
+	rawSettings := []byte(`{
+		"cpu": {"maxLimit": "2", "defaultLimit": "1", "defaultRequest": "500m"},
+		"memory": {"maxLimit": "1Gi", "defaultLimit": "512Mi", "defaultRequest": "256Mi"},
+		"imageProfiles": [
+			{"images": ["ghcr.io/example/*-jvm:*"], "memory": {"maxLimit": "8Gi", "defaultLimit": "4Gi"}}
+		],
+		"containerProfiles": [
+			{"names": ["istio-proxy", "linkerd-*"], "cpu": {"maxLimit": "500m", "defaultLimit": "200m", "defaultRequest": "100m"}},
+			{"names": ["log-shipper"], "memory": {"maxLimit": "256Mi", "defaultLimit": "128Mi", "defaultRequest": "64Mi"}}
+		]
+	}`)
+	settings := Settings{}
+	if err := json.Unmarshal(rawSettings, &settings); err != nil {
+		t.Fatalf("cannot parse settings: %v", err)
+	}
+	if err := settings.Valid(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	newContainer := func(name, image string) *corev1.Container {
+		return &corev1.Container{Name: &name, Image: image}
+	}
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{
+			newContainer("app", "ghcr.io/example/orders-jvm:v1"),
+			newContainer("istio-proxy", "istio/proxyv2:1.22"),
+			newContainer("linkerd-proxy", "ghcr.io/example/proxy-jvm:v1"),
+			newContainer("log-shipper", "fluent-bit:3"),
+		},
+	}
+	if _, _, err := validatePodSpec(podSpec, nil, "spec", &settings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []struct {
+		cpuLimit    string
+		memoryLimit string
+	}{
+		// The global settings are used when no container profile matches
+		{"1", "4Gi"},
+		{"200m", "512Mi"},
+		// The container profile is merged over the image profile
+		{"200m", "4Gi"},
+		{"1", "128Mi"},
+	}
+	for i, values := range expected {
+		resources := podSpec.Containers[i].Resources
+		if limit := resources.Limits["cpu"]; limit == nil || string(*limit) != values.cpuLimit {
+			t.Errorf("container %d: invalid cpu limit. Expected %s, got %v", i, values.cpuLimit, limit)
+		}
+		if limit := resources.Limits["memory"]; limit == nil || string(*limit) != values.memoryLimit {
+			t.Errorf("container %d: invalid memory limit. Expected %s, got %v", i, values.memoryLimit, limit)
+		}
+	}
+	if settings.Cpu.DefaultLimit.String() != "1" {
+		t.Errorf("the original settings should not be changed")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// CustomResource defines where the PodSpecs, or the lists of containers, of
+// the objects of a kind not known by the policy are found. Paths are lists of
+// field names separated by dots, like "spec.template.spec"
+type CustomResource struct {
+	Group string `json:"group"`
+	// All the versions match when it is empty
+	Version string `json:"version,omitempty"`
+	Kind    string `json:"kind"`
+	// Paths of the PodSpecs, like the pod template of an Argo Rollout
+	PodSpecPaths []string `json:"podSpecPaths,omitempty"`
+	// Paths of the lists of containers, like the steps of a Tekton TaskRun
+	ContainerPaths []string `json:"containerPaths,omitempty"`
+	// Field of the containers of the containerPaths lists defining their
+	// resources, like the computeResources of the Tekton v1 steps. Default:
+	// resources
+	ResourcesField string `json:"resourcesField,omitempty"`
+}
+
+// defaultResourcesField is the field defining the resources of a container
+const defaultResourcesField = "resources"
+
+func (c *CustomResource) resourcesField() string {
+	if c.ResourcesField == "" {
+		return defaultResourcesField
+	}
+	return c.ResourcesField
+}
+
+func validObjectPath(path string) error {
+	for _, field := range strings.Split(path, ".") {
+		if field == "" {
+			return fmt.Errorf("invalid path '%s'", path)
+		}
+	}
+	return nil
+}
+
+func (c *CustomResource) valid() error {
+	if c.Kind == "" {
+		return fmt.Errorf("the kind must be defined")
+	}
+	if len(c.PodSpecPaths) == 0 && len(c.ContainerPaths) == 0 {
+		return fmt.Errorf("at least one podSpecPaths or containerPaths entry must be defined")
+	}
+	for _, path := range append(c.PodSpecPaths, c.ContainerPaths...) {
+		if err := validObjectPath(path); err != nil {
+			return err
+		}
+	}
+	if c.ResourcesField != "" {
+		if len(c.ContainerPaths) == 0 {
+			return fmt.Errorf("the resourcesField is used only by the containerPaths entries")
+		}
+		if strings.Contains(c.ResourcesField, ".") {
+			return fmt.Errorf("invalid resourcesField '%s'. It must be a field of the containers", c.ResourcesField)
+		}
+	}
+	// The labels and the annotations of the pods are read from the metadata
+	// field next to the spec field
+	for _, path := range c.PodSpecPaths {
+		if path != "spec" && !strings.HasSuffix(path, ".spec") {
+			return fmt.Errorf("invalid podSpecPaths entry '%s'. The PodSpec must be the spec field of a pod or of a pod template, like spec.template.spec", path)
+		}
+	}
+	return nil
+}
+
+// matches returns true when the custom resource is defined for the given
+// group, version and kind
+func (c *CustomResource) matches(gvk kubewarden_protocol.GroupVersionKind) bool {
+	return c.Group == gvk.Group && c.Kind == gvk.Kind && (c.Version == "" || c.Version == gvk.Version)
+}
+
+func (s *Settings) validCustomResources() error {
+	for i := range s.CustomResources {
+		if err := s.CustomResources[i].valid(); err != nil {
+			return errors.Join(fmt.Errorf("invalid customResources[%d] settings", i), err)
+		}
+	}
+	return nil
+}
+
+// podTarget is a PodSpec, or a list of containers, validated in an object
+type podTarget struct {
+	path string
+	// The target is a list of containers, instead of a PodSpec
+	containerList bool
+	// The field defining the resources of the containers of the list
+	resourcesField string
+	// The target is the PodSpec of a kind known by the Kubewarden SDK
+	builtin bool
+}
+
+// podTargets returns the PodSpecs and the lists of containers validated in
+// the objects of the given kind. The first custom resource matching the kind
+// is used. Otherwise, the kind is handled as one of the kinds known by the
+// Kubewarden SDK
+func (s *Settings) podTargets(gvk kubewarden_protocol.GroupVersionKind) []podTarget {
+	for i := range s.CustomResources {
+		customResource := &s.CustomResources[i]
+		if !customResource.matches(gvk) {
+			continue
+		}
+		targets := []podTarget{}
+		for _, path := range customResource.PodSpecPaths {
+			targets = append(targets, podTarget{path: path})
+		}
+		for _, path := range customResource.ContainerPaths {
+			targets = append(targets, podTarget{path: path, containerList: true, resourcesField: customResource.resourcesField()})
+		}
+		return targets
+	}
+	return []podTarget{{path: podSpecPath(gvk.Kind), builtin: true}}
+}
+
+// podTargetSpec returns the PodSpec of the target, with the pod level
+// resources. The lists of containers are returned as a PodSpec defining only
+// the containers. It returns a nil PodSpec when the object of the request does
+// not define the target, unless the target is the PodSpec of a known kind
+func podTargetSpec(validationRequest kubewarden_protocol.ValidationRequest, object Object, target podTarget) (*corev1.PodSpec, *corev1.ResourceRequirements, error) {
+	if target.containerList {
+		containers, err := object.containerList(target.path, target.resourcesField)
+		if err != nil || containers == nil {
+			return nil, nil, err
+		}
+		return &corev1.PodSpec{Containers: containers}, nil, nil
+	}
+	var podSpec *corev1.PodSpec
+	if target.builtin {
+		// The Kubewarden SDK expects the PodSpec to be defined
+		if _, err := object.lookup(target.path); err != nil {
+			return nil, nil, err
+		}
+		spec, err := kubewarden.ExtractPodSpecFromObject(validationRequest)
+		if err != nil {
+			return nil, nil, err
+		}
+		podSpec = &spec
+	} else {
+		spec, err := object.podSpec(target.path)
+		if err != nil || spec == nil {
+			return nil, nil, err
+		}
+		podSpec = spec
+	}
+	podResources, err := object.podLevelResources(target.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return podSpec, podResources, nil
+}
+
+// targetMetadata returns the labels and the annotations used to select the
+// profiles of the target: the ones of the pod template of a PodSpec, or the
+// ones of the object for the lists of containers
+func (o Object) targetMetadata(target podTarget) (map[string]string, map[string]string) {
+	if target.containerList {
+		return o.labels(), o.annotations()
+	}
+	return o.podMetadata(target.path)
+}
+
+// validateContainerList validates and mutates a list of containers of a
+// custom resource. The pod level checks are not applied, because the pod
+// running the containers is not known.
+func validateContainerList(containers []*corev1.Container, listPath, resourcesField string, settings *Settings) (bool, []error, error) {
+	mutated := false
+	violations, warnings := []error{}, []error{}
+	for i, container := range containers {
+		containerPath := fmt.Sprintf("%s[%d]", listPath, i)
+		containerMutated, containerWarnings, containerViolations := validatePodContainer(container, ContainerActionDefault, containerPath, "container", nil, settings)
+		mutated = mutated || containerMutated
+		warnings = append(warnings, renameResourcesField(containerWarnings, containerPath, resourcesField)...)
+		violations = append(violations, renameResourcesField(containerViolations, containerPath, resourcesField)...)
+	}
+	return mutated, warnings, errors.Join(violations...)
+}
+
+// renameResourcesField replaces the resources field in the paths of the
+// violations of a container with the field used by the list of containers
+func renameResourcesField(violations []error, containerPath, resourcesField string) []error {
+	if resourcesField == defaultResourcesField {
+		return violations
+	}
+	prefix := fmt.Sprintf("%s.%s", containerPath, defaultResourcesField)
+	for i, violation := range violations {
+		ve, ok := violation.(violationError)
+		if !ok {
+			continue
+		}
+		if rest, found := strings.CutPrefix(ve.field, prefix); found && (rest == "" || strings.HasPrefix(rest, ".")) {
+			ve.field = fmt.Sprintf("%s.%s%s", containerPath, resourcesField, rest)
+			violations[i] = ve
+		}
+	}
+	return violations
+}
+
+// validatePodTarget validates and mutates the PodSpec, or the list of
+// containers, of the target
+func validatePodTarget(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements, target podTarget, settings *Settings) (bool, []error, error) {
+	if target.containerList {
+		return validateContainerList(pod.Containers, target.path, target.resourcesField, settings)
+	}
+	return validatePodSpec(pod, podResources, target.path, settings)
+}
+
+// setPodTargetResources mutates the object, setting the resources of the
+// containers of the target
+func (o Object) setPodTargetResources(target podTarget, pod *corev1.PodSpec) error {
+	if target.containerList {
+		return o.setContainerListResources(target.path, target.resourcesField, pod.Containers)
+	}
+	return o.setPodSpecResources(target.path, pod)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const customResourcesSettings = `{
+	"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"},
+	"customResources": [
+		{"group": "argoproj.io", "kind": "Rollout", "podSpecPaths": ["spec.template.spec"]},
+		{"group": "tekton.dev", "version": "v1", "kind": "TaskRun", "containerPaths": ["spec.taskSpec.steps", "spec.taskSpec.sidecars"], "resourcesField": "computeResources"}
+	]
+}`
+
+const rollout = `{
+	"apiVersion": "argoproj.io/v1alpha1",
+	"kind": "Rollout",
+	"metadata": {"name": "orders"},
+	"spec": {
+		"strategy": {"canary": {"steps": [{"setWeight": 20}]}},
+		"template": {
+			"metadata": {"labels": {"app": "orders"}},
+			"spec": {
+				"containers": [{"name": "orders", "image": "orders:v1", "unknownField": "value"}]
+			}
+		}
+	}
+}`
+
+const taskRun = `{
+	"apiVersion": "tekton.dev/v1",
+	"kind": "TaskRun",
+	"metadata": {"name": "build"},
+	"spec": {
+		"taskSpec": {
+			"steps": [
+				{"name": "compile", "image": "golang:1.22", "script": "go build ./...", "computeResources": {"limits": {"cpu": "4"}}}
+			]
+		}
+	}
+}`
+
+func customResourceUpdate(gvk kubewarden_protocol.GroupVersionKind, object string) func(*kubewarden_protocol.KubernetesAdmissionRequest) {
+	return func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+		request.Kind = gvk
+		request.Object = []byte(object)
+	}
+}
+
+func TestCustomResourcePodSpec(t *testing.T) {
+	gvk := kubewarden_protocol.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	response := validateTestRequest(t, "test_data/pod_without_resources.json", customResourcesSettings, customResourceUpdate(gvk, rollout))
+	if !response.Accepted || response.MutatedObject == nil {
+		t.Fatalf("expected the request to be accepted and mutated: %v", response.Message)
+	}
+	spec := response.MutatedObject.(map[string]interface{})["spec"].(map[string]interface{})
+	if _, found := spec["strategy"]; !found {
+		t.Errorf("the fields of the custom resource were not preserved: %v", spec)
+	}
+	containers := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+	expectedContainer := map[string]interface{}{
+		"name":         "orders",
+		"image":        "orders:v1",
+		"unknownField": "value",
+		"resources": map[string]interface{}{
+			"limits":   map[string]interface{}{"cpu": "200m"},
+			"requests": map[string]interface{}{"cpu": "100m"},
+		},
+	}
+	if diff := cmp.Diff(expectedContainer, containers[0]); diff != "" {
+		t.Errorf("invalid mutated container: %s", diff)
+	}
+}
+
+func TestCustomResourceContainerList(t *testing.T) {
+	gvk := kubewarden_protocol.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "TaskRun"}
+	response := validateTestRequest(t, "test_data/pod_without_resources.json", customResourcesSettings, customResourceUpdate(gvk, taskRun))
+	if response.Accepted {
+		t.Fatal("the request should be rejected")
+	}
+	if !strings.Contains(*response.Message, "spec.taskSpec.steps[0].computeResources.limits.cpu (container 'compile'): cpu limit '4' exceeds the max allowed value '1'") {
+		t.Errorf("invalid message: %s", *response.Message)
+	}
+
+	// The sidecars are not defined by the TaskRun. Therefore, they are not
+	// checked
+	validTaskRun := strings.Replace(taskRun, `"cpu": "4"`, `"cpu": "500m"`, 1)
+	response = validateTestRequest(t, "test_data/pod_without_resources.json", customResourcesSettings, customResourceUpdate(gvk, validTaskRun))
+	if !response.Accepted || response.MutatedObject == nil {
+		t.Fatalf("expected the request to be accepted and mutated: %v", response.Message)
+	}
+	taskSpec := response.MutatedObject.(map[string]interface{})["spec"].(map[string]interface{})["taskSpec"].(map[string]interface{})
+	if _, found := taskSpec["sidecars"]; found {
+		t.Errorf("the missing list of containers was added: %v", taskSpec)
+	}
+	// The resources of the Tekton v1 steps are defined by the computeResources
+	// field
+	expectedStep := map[string]interface{}{
+		"name":   "compile",
+		"image":  "golang:1.22",
+		"script": "go build ./...",
+		"computeResources": map[string]interface{}{
+			"limits":   map[string]interface{}{"cpu": "500m"},
+			"requests": map[string]interface{}{"cpu": "100m"},
+		},
+	}
+	if diff := cmp.Diff(expectedStep, taskSpec["steps"].([]interface{})[0]); diff != "" {
+		t.Errorf("invalid mutated step: %s", diff)
+	}
+}
+
+func TestCustomResourceVersion(t *testing.T) {
+	// Only the v1 TaskRuns are configured. The other versions are not known by
+	// the policy
+	gvk := kubewarden_protocol.GroupVersionKind{Group: "tekton.dev", Version: "v1beta1", Kind: "TaskRun"}
+	response := validateTestRequest(t, "test_data/pod_without_resources.json", customResourcesSettings, customResourceUpdate(gvk, taskRun))
+	if response.Accepted {
+		t.Error("the request should be rejected")
+	}
+}
+
+func TestCustomResourcePodTemplateLabels(t *testing.T) {
+	// The profile is selected by the labels of the pod template of the Rollout
+	rawSettings := strings.Replace(customResourcesSettings, `"customResources"`, `"profiles": [{"name": "orders", "selector": {"matchLabels": {"app": "orders"}}, "cpu": {"defaultLimit": "500m"}}],
+	"customResources"`, 1)
+	gvk := kubewarden_protocol.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	response := validateTestRequest(t, "test_data/pod_without_resources.json", rawSettings, customResourceUpdate(gvk, rollout))
+	if !response.Accepted || response.MutatedObject == nil {
+		t.Fatalf("expected the request to be accepted and mutated: %v", response.Message)
+	}
+	spec := response.MutatedObject.(map[string]interface{})["spec"].(map[string]interface{})
+	container := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})
+	limits := container["resources"].(map[string]interface{})["limits"]
+	if diff := cmp.Diff(map[string]interface{}{"cpu": "500m"}, limits); diff != "" {
+		t.Errorf("the profile of the pod template was not applied: %s", diff)
+	}
+}
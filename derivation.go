@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	"gopkg.in/inf.v0"
+)
+
+// Strategies used to compute the missing requests
+const (
+	// Use the default request
+	RequestDerivationDefault = "default"
+	// Multiply the limit of the container by the requestFactor
+	RequestDerivationLimitFactor = "limitFactor"
+	// Use the lowest value between the default request and the limit of the
+	// container, like Kubernetes copying the limit into the missing request
+	RequestDerivationMinDefaultLimit = "minDefaultLimit"
+)
+
+// Strategies used to compute the missing limits
+const (
+	// Use the default limit
+	LimitDerivationDefault = "default"
+	// Multiply the request of the container by the limitFactor
+	LimitDerivationRequestFactor = "requestFactor"
+)
+
+func (r *ResourceConfiguration) validDerivations() error {
+	one := resource.MustParse("1")
+	switch r.RequestDerivation {
+	case "", RequestDerivationDefault:
+	case RequestDerivationLimitFactor:
+		if r.RequestFactor.Sign() <= 0 || r.RequestFactor.Cmp(one) > 0 {
+			return fmt.Errorf("the %s request derivation requires a requestFactor greater than 0 and less than or equal to 1", r.RequestDerivation)
+		}
+	case RequestDerivationMinDefaultLimit:
+		if r.DefaultRequest.IsZero() {
+			return fmt.Errorf("the %s request derivation requires a defaultRequest", r.RequestDerivation)
+		}
+	default:
+		return fmt.Errorf("invalid requestDerivation value '%s'. Valid values are: %s, %s, %s", r.RequestDerivation, RequestDerivationDefault, RequestDerivationLimitFactor, RequestDerivationMinDefaultLimit)
+	}
+	switch r.LimitDerivation {
+	case "", LimitDerivationDefault:
+	case LimitDerivationRequestFactor:
+		if r.LimitFactor.Cmp(one) < 0 {
+			return fmt.Errorf("the %s limit derivation requires a limitFactor greater than or equal to 1", r.LimitDerivation)
+		}
+	default:
+		return fmt.Errorf("invalid limitDerivation value '%s'. Valid values are: %s, %s", r.LimitDerivation, LimitDerivationDefault, LimitDerivationRequestFactor)
+	}
+	return nil
+}
+
+// multiplyQuantity returns the quantity multiplied by the factor, keeping the
+// format of the quantity. CPU values are rounded up to millicores, the other
+// values to integers
+func multiplyQuantity(quantity, factor resource.Quantity, resourceName string) resource.Quantity {
+	product := resource.NewDecimalQuantity(*new(inf.Dec).Mul(quantity.AsDec(), factor.AsDec()), quantity.Format)
+	scale := resource.Scale(0)
+	if resourceName == "cpu" {
+		scale = resource.Milli
+	}
+	product.RoundUp(scale)
+	return *product
+}
+
+// derivedRequest returns the request applied to a container which does not
+// define it, according to the request derivation strategy. The default
+// request is used when the container does not define the limit either
+func derivedRequest(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration) resource.Quantity {
+	if missingResourceQuantity(container.Resources.Limits, resourceName) {
+		return resourceConfig.DefaultRequest
+	}
+	limit, err := resource.ParseQuantity(string(*container.Resources.Limits[resourceName]))
+	if err != nil {
+		return resourceConfig.DefaultRequest
+	}
+	switch resourceConfig.RequestDerivation {
+	case RequestDerivationLimitFactor:
+		return multiplyQuantity(limit, resourceConfig.RequestFactor, resourceName)
+	case RequestDerivationMinDefaultLimit:
+		if limit.Cmp(resourceConfig.DefaultRequest) < 0 {
+			return limit
+		}
+	}
+	return resourceConfig.DefaultRequest
+}
+
+// derivedLimit returns the limit computed from the request of a container
+// which does not define the limit, according to the limit derivation
+// strategy. It returns false when the limit cannot be derived, so the default
+// limit must be used
+func derivedLimit(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration) (resource.Quantity, bool) {
+	if resourceConfig.LimitDerivation != LimitDerivationRequestFactor || missingResourceQuantity(container.Resources.Requests, resourceName) {
+		return resource.Quantity{}, false
+	}
+	request, err := resource.ParseQuantity(string(*container.Resources.Requests[resourceName]))
+	if err != nil {
+		return resource.Quantity{}, false
+	}
+	return multiplyQuantity(request, resourceConfig.LimitFactor, resourceName), true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	apimachinery_pkg_api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+func TestResourceDerivation(t *testing.T) {
+	tests := []struct {
+		name              string
+		limit             string
+		request           string
+		requestDerivation string
+		requestFactor     string
+		limitDerivation   string
+		limitFactor       string
+		onExceed          string
+		expectedLimit     string
+		expectedRequest   string
+		expectedErrorMsg  string
+	}{
+		{"default request is greater than the limit", "200m", "", "", "", "", "", "", "200m", "", "cpu limit '200m' is less than the requested '500m' value"},
+		{"request from the limit", "200m", "", RequestDerivationLimitFactor, "0.5", "", "", "", "200m", "100m", ""},
+		{"request from the default limit", "", "", RequestDerivationLimitFactor, "0.5", "", "", "", "1", "500m", ""},
+		{"limit lower than the default request", "200m", "", RequestDerivationMinDefaultLimit, "", "", "", "", "200m", "200m", ""},
+		{"limit greater than the default request", "1500m", "", RequestDerivationMinDefaultLimit, "", "", "", "", "1500m", "500m", ""},
+		{"request below the min request", "50m", "", RequestDerivationMinDefaultLimit, "", "", "", "", "50m", "", "cpu request '50m' is less than the min allowed value '100m'"},
+		{"limit from the request", "", "300m", "", "", LimitDerivationRequestFactor, "2", "", "600m", "300m", ""},
+		{"limit from the default request", "", "", "", "", LimitDerivationRequestFactor, "2", "", "1", "500m", ""},
+		{"limit from the request exceeding the max limit", "", "1500m", "", "", LimitDerivationRequestFactor, "2", "", "", "1500m", "cpu limit '3' exceeds the max allowed value '2'"},
+		{"limit from the request clamped to the max limit", "", "1500m", "", "", LimitDerivationRequestFactor, "2", OnExceedClamp, "2", "1500m", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container := corev1.Container{
+				Resources: &corev1.ResourceRequirements{
+					Limits:   map[string]*apimachinery_pkg_api_resource.Quantity{},
+					Requests: map[string]*apimachinery_pkg_api_resource.Quantity{},
+				},
+			}
+			if test.limit != "" {
+				limit := apimachinery_pkg_api_resource.Quantity(test.limit)
+				container.Resources.Limits["cpu"] = &limit
+			}
+			if test.request != "" {
+				request := apimachinery_pkg_api_resource.Quantity(test.request)
+				container.Resources.Requests["cpu"] = &request
+			}
+			settings := Settings{
+				Cpu: &ResourceConfiguration{
+					MaxLimit:          resource.MustParse("2"),
+					MinRequest:        resource.MustParse("100m"),
+					DefaultLimit:      resource.MustParse("1"),
+					DefaultRequest:    resource.MustParse("500m"),
+					RequestDerivation: test.requestDerivation,
+					LimitDerivation:   test.limitDerivation,
+					OnExceed:          test.onExceed,
+				},
+			}
+			if test.requestFactor != "" {
+				settings.Cpu.RequestFactor = resource.MustParse(test.requestFactor)
+			}
+			if test.limitFactor != "" {
+				settings.Cpu.LimitFactor = resource.MustParse(test.limitFactor)
+			}
+			_, err := validateAndAdjustContainer(&container, &settings)
+			if len(test.expectedErrorMsg) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErrorMsg) {
+					t.Errorf("invalid error message. Expected the string '%s' in the error. Got '%v'", test.expectedErrorMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if limit := container.Resources.Limits["cpu"]; limit == nil || string(*limit) != test.expectedLimit {
+				t.Errorf("expected limit '%s', got %v", test.expectedLimit, limit)
+			}
+			if request := container.Resources.Requests["cpu"]; request == nil || string(*request) != test.expectedRequest {
+				t.Errorf("expected request '%s', got %v", test.expectedRequest, request)
+			}
+		})
+	}
+}
+
+func TestMultiplyQuantity(t *testing.T) {
+	tests := []struct {
+		quantity     string
+		factor       string
+		resourceName string
+		expected     string
+	}{
+		{"1", "0.5", "cpu", "500m"},
+		{"1m", "0.5", "cpu", "1m"},
+		{"1Gi", "0.5", "memory", "512Mi"},
+		{"1G", "1.5", "memory", "1500M"},
+		{"3", "0.5", "nvidia.com/gpu", "2"},
+	}
+	for _, test := range tests {
+		t.Run(test.quantity+"*"+test.factor, func(t *testing.T) {
+			product := multiplyQuantity(resource.MustParse(test.quantity), resource.MustParse(test.factor), test.resourceName)
+			if product.String() != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, product.String())
+			}
+		})
+	}
+}
@@ -192,3 +192,12 @@
   [ "$status" -eq 0 ]
   [ $(expr "$output" : '.*allowed":true') -ne 0 ]
 }
+
+@test "accept requests from exempted namespaces" {
+  run kwctl run annotated-policy.wasm -r test_data/pod_exceeding_range.json \
+    --settings-json '{"cpu": {"maxLimit": "1m", "defaultRequest" : "1m", "defaultLimit" : "1m"}, "memory" : {"maxLimit": "1G", "defaultRequest" : "1G", "defaultLimit" : "1G"}, "ignoreNamespaces": ["default"]}'
+
+  [ "$status" -eq 0 ]
+  [ $(expr "$output" : '.*allowed":true') -ne 0 ]
+  [ $(expr "$output" : ".*namespace 'default' matches the ignoreNamespaces entry 'default'.*") -ne 0 ]
+}
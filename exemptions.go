@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	serviceAccountUsernamePrefix = "system:serviceaccount:"
+	// Audit annotation recording why a request has not been validated
+	exemptionAuditAnnotation = "exemption-reason"
+)
+
+// matchingPattern returns the first glob pattern matching the value, or an
+// empty string when no pattern matches
+func matchingPattern(value string, patterns []string) string {
+	for _, pattern := range patterns {
+		if globMatches(pattern, value) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+func validPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// validServiceAccountPatterns checks the ignoreServiceAccounts entries, which
+// must be in the <namespace>:<name> format
+func validServiceAccountPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		namespace, name, found := strings.Cut(pattern, ":")
+		if !found || namespace == "" || name == "" || strings.Contains(name, ":") {
+			return fmt.Errorf("invalid service account '%s'. The expected format is <namespace>:<name>", pattern)
+		}
+	}
+	return validPatterns(patterns)
+}
+
+func (s *Settings) validExemptions() error {
+	exemptions := []struct {
+		name     string
+		patterns []string
+	}{
+		{"ignoreNamespaces", s.IgnoreNamespaces},
+		{"ignoreUsers", s.IgnoreUsers},
+		{"ignoreGroups", s.IgnoreGroups},
+	}
+	for _, exemption := range exemptions {
+		if err := validPatterns(exemption.patterns); err != nil {
+			return fmt.Errorf("invalid %s settings: %w", exemption.name, err)
+		}
+	}
+	if err := validServiceAccountPatterns(s.IgnoreServiceAccounts); err != nil {
+		return fmt.Errorf("invalid ignoreServiceAccounts settings: %w", err)
+	}
+	return nil
+}
+
+// exemptionReason returns why the request is exempted from the validation,
+// or an empty string when the request must be validated. The namespace of the
+// request and the user sending it are checked
+func (s *Settings) exemptionReason(request *kubewarden_protocol.KubernetesAdmissionRequest) string {
+	if pattern := matchingPattern(request.Namespace, s.IgnoreNamespaces); pattern != "" {
+		return fmt.Sprintf("namespace '%s' matches the ignoreNamespaces entry '%s'", request.Namespace, pattern)
+	}
+	username := request.UserInfo.Username
+	if serviceAccount, found := strings.CutPrefix(username, serviceAccountUsernamePrefix); found {
+		if pattern := matchingPattern(serviceAccount, s.IgnoreServiceAccounts); pattern != "" {
+			return fmt.Sprintf("service account '%s' matches the ignoreServiceAccounts entry '%s'", serviceAccount, pattern)
+		}
+	}
+	if pattern := matchingPattern(username, s.IgnoreUsers); pattern != "" {
+		return fmt.Sprintf("user '%s' matches the ignoreUsers entry '%s'", username, pattern)
+	}
+	for _, group := range request.UserInfo.Groups {
+		if pattern := matchingPattern(group, s.IgnoreGroups); pattern != "" {
+			return fmt.Sprintf("group '%s' matches the ignoreGroups entry '%s'", group, pattern)
+		}
+	}
+	return ""
+}
+
+// DefaultExemptionAnnotation is the annotation used to exempt a workload when
+// the settings do not define another one
+const DefaultExemptionAnnotation = "container-resources.kubewarden.io/exempt"
+
+// now returns the current time. It can be replaced by the tests
+var now = time.Now
+
+// ExemptionAnnotationConfiguration allows users to exempt their workloads
+// using an annotation. The annotation is ignored when it is not configured
+type ExemptionAnnotationConfiguration struct {
+	Annotation        string `json:"annotation,omitempty"`
+	RequireExpiration bool   `json:"requireExpiration,omitempty"`
+}
+
+func (c *ExemptionAnnotationConfiguration) annotation() string {
+	if c.Annotation == "" {
+		return DefaultExemptionAnnotation
+	}
+	return c.Annotation
+}
+
+// annotationExemption is the exemption requested by an annotation
+type annotationExemption struct {
+	reason  string
+	expires *time.Time
+	// The exempted containers. All the containers are exempted when empty
+	containers []string
+}
+
+// parseAnnotationExemption parses the value of the exemption annotation. The
+// value is a comma separated list of key=value pairs: "reason" is required,
+// "expires" is an optional date (YYYY-MM-DD) or timestamp (RFC 3339), and
+// "containers" is an optional list of container names separated by ";"
+func parseAnnotationExemption(value string) (*annotationExemption, error) {
+	exemption := &annotationExemption{}
+	for _, field := range strings.Split(value, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		key, fieldValue, found := strings.Cut(field, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid field '%s'. The expected format is key=value", strings.TrimSpace(field))
+		}
+		fieldValue = strings.TrimSpace(fieldValue)
+		switch strings.TrimSpace(key) {
+		case "reason":
+			exemption.reason = fieldValue
+		case "expires":
+			// The exemptions expiring on a date are valid for the whole day
+			expires, err := time.Parse(time.DateOnly, fieldValue)
+			if err == nil {
+				expires = expires.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			} else {
+				expires, err = time.Parse(time.RFC3339, fieldValue)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid expiration '%s'. The expected format is YYYY-MM-DD or RFC 3339", fieldValue)
+			}
+			exemption.expires = &expires
+		case "containers":
+			for _, name := range strings.Split(fieldValue, ";") {
+				if name = strings.TrimSpace(name); name != "" {
+					exemption.containers = append(exemption.containers, name)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown field '%s'", strings.TrimSpace(key))
+		}
+	}
+	if exemption.reason == "" {
+		return nil, fmt.Errorf("a non-empty reason is required")
+	}
+	return exemption, nil
+}
+
+// annotationExemption returns the exemption requested by the annotations of
+// the object, or nil when there is no exemption annotation or the settings
+// do not allow it. Invalid and expired exemptions are returned as errors
+func (s *Settings) annotationExemption(annotations map[string]string) (*annotationExemption, error) {
+	if s.ExemptionAnnotation == nil {
+		return nil, nil
+	}
+	annotation := s.ExemptionAnnotation.annotation()
+	value, found := annotations[annotation]
+	if !found {
+		return nil, nil
+	}
+	exemption, err := parseAnnotationExemption(value)
+	if err != nil {
+		return nil, newRuleError(RuleExemptionAnnotation, "", value, "", "invalid %s annotation: %s", annotation, err)
+	}
+	if exemption.expires == nil && s.ExemptionAnnotation.RequireExpiration {
+		return nil, newRuleError(RuleExemptionAnnotation, "", value, "", "invalid %s annotation: an expiration is required", annotation)
+	}
+	if exemption.expires != nil && !now().Before(*exemption.expires) {
+		expires := exemption.expires.Format(time.RFC3339)
+		return nil, newRuleError(RuleExemptionAnnotation, "", expires, "", "the exemption of the %s annotation expired on %s", annotation, expires)
+	}
+	return exemption, nil
+}
+
+// objectExemption returns the exemption requested by the annotation of the
+// object, or of its pod templates. The annotation of the pod templates takes
+// precedence. Invalid and expired exemptions do not exempt the object: they
+// are returned as violations of the annotation
+func (s *Settings) objectExemption(object Object, targets []podTarget) (*annotationExemption, error) {
+	if s.ExemptionAnnotation == nil {
+		return nil, nil
+	}
+	annotation := s.ExemptionAnnotation.annotation()
+	annotations := object.annotations()
+	field := fmt.Sprintf("metadata.annotations[%s]", annotation)
+	for _, target := range targets {
+		if target.containerList {
+			continue
+		}
+		_, podAnnotations := object.podMetadata(target.path)
+		if value, found := podAnnotations[annotation]; found {
+			annotations[annotation] = value
+			field = fmt.Sprintf("%smetadata.annotations[%s]", strings.TrimSuffix(target.path, "spec"), annotation)
+		}
+	}
+	exemption, err := s.annotationExemption(annotations)
+	if err != nil {
+		return nil, violationError{field: field, err: err}
+	}
+	return exemption, nil
+}
+
+// String returns the description of the exemption recorded in the audit
+// annotation
+func (e *annotationExemption) String() string {
+	description := fmt.Sprintf("exempted by annotation: %s", e.reason)
+	if e.expires != nil {
+		description += fmt.Sprintf(", expires %s", e.expires.Format(time.RFC3339))
+	}
+	if len(e.containers) > 0 {
+		description += fmt.Sprintf(", containers %s", strings.Join(e.containers, ", "))
+	}
+	return description
+}
+
+// withExemptContainers returns a copy of the settings where the containers
+// with the given names are not checked
+func (s *Settings) withExemptContainers(names []string) *Settings {
+	settings := *s
+	settings.exemptContainers = names
+	return &settings
+}
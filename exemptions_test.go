@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestExemptionReason(t *testing.T) {
+	settings := Settings{
+		IgnoreNamespaces:      []string{"kube-system", "platform-*"},
+		IgnoreServiceAccounts: []string{"ci:builder", "tools:*"},
+		IgnoreUsers:           []string{"admin@example.com"},
+		IgnoreGroups:          []string{"sre-*"},
+	}
+	tests := []struct {
+		name           string
+		namespace      string
+		username       string
+		groups         []string
+		expectedReason string
+	}{
+		{"no exemption", "default", "jane@example.com", []string{"developers"}, ""},
+		{"namespace", "kube-system", "jane@example.com", []string{}, "namespace 'kube-system' matches the ignoreNamespaces entry 'kube-system'"},
+		{"namespace pattern", "platform-logging", "jane@example.com", []string{}, "namespace 'platform-logging' matches the ignoreNamespaces entry 'platform-*'"},
+		{"service account", "default", "system:serviceaccount:ci:builder", []string{}, "service account 'ci:builder' matches the ignoreServiceAccounts entry 'ci:builder'"},
+		{"service account pattern", "default", "system:serviceaccount:tools:deployer", []string{}, "service account 'tools:deployer' matches the ignoreServiceAccounts entry 'tools:*'"},
+		{"service account of another namespace", "default", "system:serviceaccount:default:builder", []string{}, ""},
+		{"user", "default", "admin@example.com", []string{}, "user 'admin@example.com' matches the ignoreUsers entry 'admin@example.com'"},
+		{"group", "default", "jane@example.com", []string{"developers", "sre-oncall"}, "group 'sre-oncall' matches the ignoreGroups entry 'sre-*'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := kubewarden_protocol.KubernetesAdmissionRequest{
+				Namespace: test.namespace,
+				UserInfo:  kubewarden_protocol.UserInfo{Username: test.username, Groups: test.groups},
+			}
+			if reason := settings.exemptionReason(&request); reason != test.expectedReason {
+				t.Errorf("invalid exemption reason. Expected '%s', got '%s'", test.expectedReason, reason)
+			}
+		})
+	}
+}
+
+func TestExemptedRequestsAreAccepted(t *testing.T) {
+	rawSettings := `{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "ignoreGroups": ["system:masters"]}`
+	response := validateTestRequest(t, "test_data/pod_exceeding_range.json", rawSettings, nil)
+	if !response.Accepted {
+		t.Fatalf("the request should be accepted")
+	}
+	expectedReason := "group 'system:masters' matches the ignoreGroups entry 'system:masters'"
+	if reason := response.AuditAnnotations[exemptionAuditAnnotation]; reason != expectedReason {
+		t.Errorf("invalid exemption reason. Expected '%s', got '%s'", expectedReason, reason)
+	}
+
+	response = validateTestRequest(t, "test_data/pod_exceeding_range.json", rawSettings, func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+		request.UserInfo.Groups = []string{"system:authenticated"}
+	})
+	if response.Accepted {
+		t.Errorf("the request should be rejected")
+	}
+	if reason, found := response.AuditAnnotations[exemptionAuditAnnotation]; found {
+		t.Errorf("unexpected exemption reason: %s", reason)
+	}
+}
+
+func TestParseAnnotationExemption(t *testing.T) {
+	expires := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	endOfDay := time.Date(2026, 12, 1, 23, 59, 59, 999999999, time.UTC)
+	tests := []struct {
+		name          string
+		value         string
+		expected      *annotationExemption
+		expectedError string
+	}{
+		{"reason only", "reason=batch migration", &annotationExemption{reason: "batch migration"}, ""},
+		{"reason and expiration date", "reason=batch migration, expires=2026-12-01", &annotationExemption{reason: "batch migration", expires: &endOfDay}, ""},
+		{"expiration timestamp", "expires=2026-12-01T00:00:00Z, reason=load test", &annotationExemption{reason: "load test", expires: &expires}, ""},
+		{"containers", "reason=legacy app, containers=app; log-shipper", &annotationExemption{reason: "legacy app", containers: []string{"app", "log-shipper"}}, ""},
+		{"missing reason", "expires=2026-12-01", nil, "a non-empty reason is required"},
+		{"empty reason", "reason= , expires=2026-12-01", nil, "a non-empty reason is required"},
+		{"invalid expiration", "reason=test, expires=next week", nil, "invalid expiration 'next week'"},
+		{"invalid field", "reason=test, forever", nil, "invalid field 'forever'. The expected format is key=value"},
+		{"unknown field", "reason=test, owner=me", nil, "unknown field 'owner'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exemption, err := parseAnnotationExemption(test.value)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Fatalf("expected error '%s', got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, exemption, cmp.AllowUnexported(annotationExemption{})); diff != "" {
+				t.Errorf("invalid exemption: %s", diff)
+			}
+		})
+	}
+}
+
+// setTestAnnotation returns an update function adding the annotation to the
+// object of the request
+func setTestAnnotation(t *testing.T, annotation, value string) func(*kubewarden_protocol.KubernetesAdmissionRequest) {
+	return func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+		object, err := newObject(request.Object)
+		if err != nil {
+			t.Fatalf("cannot parse the object: %v", err)
+		}
+		metadata, err := object.lookup("metadata")
+		if err != nil {
+			t.Fatalf("cannot find the object metadata: %v", err)
+		}
+		metadata["annotations"] = map[string]interface{}{annotation: value}
+		if request.Object, err = json.Marshal(object); err != nil {
+			t.Fatalf("cannot marshal the object: %v", err)
+		}
+	}
+}
+
+func TestAnnotationExemption(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	rawSettings := `{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "exemptionAnnotation": {}}`
+	tests := []struct {
+		name                 string
+		rawSettings          string
+		annotation           string
+		expectedAccepted     bool
+		expectedMessage      string
+		expectedAuditMessage string
+	}{
+		{
+			"exempted pod",
+			rawSettings,
+			"reason=capacity test, expires=2026-12-01",
+			true, "",
+			"exempted by annotation: capacity test, expires 2026-12-01T23:59:59Z",
+		},
+		{
+			"annotation not allowed by the settings",
+			`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}}`,
+			"reason=capacity test",
+			false, "cpu limit '3m' exceeds the max allowed value '1m'", "",
+		},
+		{
+			"expired exemption",
+			rawSettings,
+			"reason=capacity test, expires=2026-10-01",
+			false, "metadata.annotations[container-resources.kubewarden.io/exempt]: the exemption of the container-resources.kubewarden.io/exempt annotation expired on 2026-10-01T23:59:59Z", "",
+		},
+		{
+			"expired exemption does not exempt the pod",
+			rawSettings,
+			"reason=capacity test, expires=2026-10-01",
+			false, "spec.containers[1].resources.limits.cpu (container 'mycontainer'): cpu limit '2m' exceeds the max allowed value '1m'", "",
+		},
+		{
+			"missing reason",
+			rawSettings,
+			"expires=2026-12-01",
+			false, "invalid container-resources.kubewarden.io/exempt annotation: a non-empty reason is required", "",
+		},
+		{
+			"missing required expiration",
+			`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "exemptionAnnotation": {"requireExpiration": true}}`,
+			"reason=capacity test",
+			false, "invalid container-resources.kubewarden.io/exempt annotation: an expiration is required", "",
+		},
+		{
+			"exempted containers",
+			rawSettings,
+			"reason=capacity test, containers=pause;mycontainer",
+			true, "",
+			"exempted by annotation: capacity test, containers pause, mycontainer",
+		},
+		{
+			"other containers are validated",
+			rawSettings,
+			"reason=capacity test, containers=pause",
+			false, "spec.containers[1].resources.limits.cpu (container 'mycontainer'): cpu limit '2m' exceeds the max allowed value '1m'",
+			"exempted by annotation: capacity test, containers pause",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validateTestRequest(t, "test_data/pod_exceeding_range.json", test.rawSettings,
+				setTestAnnotation(t, DefaultExemptionAnnotation, test.annotation))
+			if response.Accepted != test.expectedAccepted {
+				t.Fatalf("invalid response. Expected accepted %t, got %t: %v", test.expectedAccepted, response.Accepted, response.Message)
+			}
+			if test.expectedMessage != "" && (response.Message == nil || !strings.Contains(*response.Message, test.expectedMessage)) {
+				t.Errorf("invalid message. Expected the string '%s', got %v", test.expectedMessage, response.Message)
+			}
+			if auditMessage := response.AuditAnnotations[exemptionAuditAnnotation]; auditMessage != test.expectedAuditMessage {
+				t.Errorf("invalid audit annotation. Expected '%s', got '%s'", test.expectedAuditMessage, auditMessage)
+			}
+		})
+	}
+}
+
+func TestExpiredAnnotationExemptionModes(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	expiredMessage := "metadata.annotations[container-resources.kubewarden.io/exempt]: the exemption of the container-resources.kubewarden.io/exempt annotation expired on 2026-10-01T23:59:59Z"
+	tests := []struct {
+		name             string
+		mode             string
+		expectedAccepted bool
+		expectedWarning  bool
+	}{
+		{"enforce", "enforce", false, false},
+		{"warn", "warn", true, true},
+		{"audit", "audit", true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rawSettings := fmt.Sprintf(`{"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"}, "exemptionAnnotation": {}, "mode": "%s"}`, test.mode)
+			response := validateTestRequest(t, "test_data/pod_without_resources.json", rawSettings,
+				setTestAnnotation(t, DefaultExemptionAnnotation, "reason=capacity test, expires=2026-10-01"))
+			if response.Accepted != test.expectedAccepted {
+				t.Fatalf("invalid response. Expected accepted %t, got %t: %v", test.expectedAccepted, response.Accepted, response.Message)
+			}
+			if test.expectedAccepted && response.MutatedObject == nil {
+				t.Error("the pod without exemption should be mutated")
+			}
+			if warned := slices.Contains(response.Warnings, expiredMessage); warned != test.expectedWarning {
+				t.Errorf("invalid warnings: %v", response.Warnings)
+			}
+			if details := response.AuditAnnotations[violationDetailsAuditAnnotation]; !strings.Contains(details, `"rule":"exemptionAnnotation"`) {
+				t.Errorf("invalid violation details: %s", details)
+			}
+		})
+	}
+}
+
+func TestAnnotationExemptionDayBoundary(t *testing.T) {
+	defer func() { now = time.Now }()
+	settings := Settings{ExemptionAnnotation: &ExemptionAnnotationConfiguration{}}
+	annotations := map[string]string{DefaultExemptionAnnotation: "reason=capacity test, expires=2026-12-01"}
+	tests := []struct {
+		name            string
+		now             time.Time
+		expectedExpired bool
+	}{
+		{"beginning of the day", time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC), false},
+		{"end of the day", time.Date(2026, 12, 1, 23, 59, 59, 0, time.UTC), false},
+		{"next day", time.Date(2026, 12, 2, 0, 0, 0, 0, time.UTC), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now = func() time.Time { return test.now }
+			exemption, err := settings.annotationExemption(annotations)
+			if test.expectedExpired {
+				if err == nil || !strings.Contains(err.Error(), "expired on 2026-12-01T23:59:59Z") {
+					t.Errorf("expected the exemption to be expired, got %v, error: %v", exemption, err)
+				}
+				return
+			}
+			if err != nil || exemption == nil {
+				t.Errorf("expected the exemption to be valid, got %v, error: %v", exemption, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+// Actions taken when the limit or the request of a container grows more than
+// allowed by an UPDATE request
+const (
+	// Reject the container
+	OnGrowthExceedReject = "reject"
+	// Accept the container, returning a warning
+	OnGrowthExceedWarn = "warn"
+)
+
+func (r *ResourceConfiguration) validGrowth() error {
+	switch r.OnGrowthExceed {
+	case "", OnGrowthExceedReject, OnGrowthExceedWarn:
+	default:
+		return fmt.Errorf("invalid onGrowthExceed value '%s'. Valid values are: %s, %s", r.OnGrowthExceed, OnGrowthExceedReject, OnGrowthExceedWarn)
+	}
+	if !r.MaxGrowthFactor.IsZero() && r.MaxGrowthFactor.Cmp(resource.MustParse("1")) < 0 {
+		return fmt.Errorf("maxGrowthFactor must be greater than or equal to 1")
+	}
+	if r.MaxGrowthDelta.Sign() < 0 {
+		return fmt.Errorf("maxGrowthDelta cannot be negative")
+	}
+	return nil
+}
+
+// withOldPod returns a copy of the settings where the containers of the
+// given PodSpec, defined by the old object of an UPDATE request, are used to
+// check the growth of the resources
+func (s *Settings) withOldPod(oldPod *corev1.PodSpec) *Settings {
+	settings := *s
+	settings.oldContainers = map[string]*corev1.Container{}
+	for _, container := range oldPod.Containers {
+		settings.oldContainers[containerName(container.Name)] = container
+	}
+	for _, container := range oldPod.InitContainers {
+		settings.oldContainers[containerName(container.Name)] = container
+	}
+	for _, container := range ephemeralContainers(oldPod) {
+		settings.oldContainers[containerName(container.Name)] = container
+	}
+	return &settings
+}
+
+// maxGrowthBounds returns the max values allowed for a quantity growing from
+// the old value, with the rule defining each one of them
+func maxGrowthBounds(old resource.Quantity, resourceName string, resourceConfig *ResourceConfiguration) map[string]resource.Quantity {
+	bounds := map[string]resource.Quantity{}
+	if !resourceConfig.MaxGrowthFactor.IsZero() {
+		bounds[RuleMaxGrowthFactor] = multiplyQuantity(old, resourceConfig.MaxGrowthFactor, resourceName)
+	}
+	if !resourceConfig.MaxGrowthDelta.IsZero() {
+		bound := old.DeepCopy()
+		bound.Add(resourceConfig.MaxGrowthDelta)
+		bounds[RuleMaxGrowthDelta] = bound
+	}
+	return bounds
+}
+
+// validateResourceGrowth checks the growth of the limit or of the request of
+// the resource. Values missing in the old or in the new container are not
+// checked
+func validateResourceGrowth(resources, oldResources map[string]*api_resource.Quantity, resourceName, kind string, resourceConfig *ResourceConfiguration) error {
+	if missingResourceQuantity(resources, resourceName) || missingResourceQuantity(oldResources, resourceName) {
+		return nil
+	}
+	quantity, err := resource.ParseQuantity(string(*resources[resourceName]))
+	if err != nil {
+		return nil
+	}
+	oldQuantity, err := resource.ParseQuantity(string(*oldResources[resourceName]))
+	if err != nil {
+		return nil
+	}
+	bounds := maxGrowthBounds(oldQuantity, resourceName, resourceConfig)
+	for _, rule := range []string{RuleMaxGrowthFactor, RuleMaxGrowthDelta} {
+		bound, found := bounds[rule]
+		if found && quantity.Cmp(bound) > 0 {
+			return newRuleError(rule, resourceName, quantity.String(), bound.String(),
+				"%s %s '%s' grows too much from the previous value '%s'. The max allowed value is '%s'",
+				resourceName, kind, quantity.String(), oldQuantity.String(), bound.String())
+		}
+	}
+	return nil
+}
+
+// validateContainerGrowth checks how much the limits and the requests of the
+// container grow from the values of the old object of an UPDATE request. The
+// new containers are not checked. The values are checked after the mutation.
+// It returns the warnings and the violations, according to the
+// onGrowthExceed action of each resource
+func validateContainerGrowth(container *corev1.Container, settings *Settings) ([]error, error) {
+	oldContainer, found := settings.oldContainers[containerName(container.Name)]
+	if !found || container.Resources == nil || oldContainer.Resources == nil {
+		return nil, nil
+	}
+	violations, warnings := []error{}, []error{}
+	for _, resourceName := range settings.resourceNames() {
+		resourceConfig := settings.resourceConfiguration(resourceName)
+		if resourceConfig.MaxGrowthFactor.IsZero() && resourceConfig.MaxGrowthDelta.IsZero() {
+			continue
+		}
+		errs := []error{}
+		if err := validateResourceGrowth(container.Resources.Limits, oldContainer.Resources.Limits, resourceName, "limit", resourceConfig); err != nil {
+			errs = append(errs, limitError(resourceName, err))
+		}
+		if err := validateResourceGrowth(container.Resources.Requests, oldContainer.Resources.Requests, resourceName, "request", resourceConfig); err != nil {
+			errs = append(errs, requestError(resourceName, err))
+		}
+		if resourceConfig.OnGrowthExceed == OnGrowthExceedWarn {
+			warnings = append(warnings, errs...)
+		} else {
+			violations = append(violations, errs...)
+		}
+	}
+	return warnings, errors.Join(violations...)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestContainerGrowth(t *testing.T) {
+	newNamedContainer := func(name, limit, request string) *corev1.Container {
+		container := newContainerWithCpu(limit, request)
+		container.Name = &name
+		return container
+	}
+	oldPod := &corev1.PodSpec{
+		Containers: []*corev1.Container{newNamedContainer("app", "1", "500m")},
+	}
+	tests := []struct {
+		name             string
+		container        *corev1.Container
+		resourceConfig   ResourceConfiguration
+		expectedErrorMsg string
+		expectedWarning  string
+	}{
+		{"growth within the factor", newNamedContainer("app", "2", "1"), ResourceConfiguration{MaxGrowthFactor: resource.MustParse("2")}, "", ""},
+		{"limit exceeding the factor", newNamedContainer("app", "3", "1"), ResourceConfiguration{MaxGrowthFactor: resource.MustParse("2")}, "cpu limit '3' grows too much from the previous value '1'. The max allowed value is '2'", ""},
+		{"request exceeding the factor", newNamedContainer("app", "1", "1500m"), ResourceConfiguration{MaxGrowthFactor: resource.MustParse("2")}, "cpu request '1500m' grows too much from the previous value '500m'. The max allowed value is '1'", ""},
+		{"growth within the delta", newNamedContainer("app", "1500m", "1"), ResourceConfiguration{MaxGrowthDelta: resource.MustParse("500m")}, "", ""},
+		{"limit exceeding the delta", newNamedContainer("app", "2", "1"), ResourceConfiguration{MaxGrowthDelta: resource.MustParse("500m")}, "cpu limit '2' grows too much from the previous value '1'. The max allowed value is '1500m'", ""},
+		{"both bounds are enforced", newNamedContainer("app", "1500m", "500m"), ResourceConfiguration{MaxGrowthFactor: resource.MustParse("10"), MaxGrowthDelta: resource.MustParse("250m")}, "The max allowed value is '1250m'", ""},
+		{"shrinking is allowed", newNamedContainer("app", "100m", "100m"), ResourceConfiguration{MaxGrowthFactor: resource.MustParse("1")}, "", ""},
+		{"growth warning", newNamedContainer("app", "3", "500m"), ResourceConfiguration{MaxGrowthFactor: resource.MustParse("2"), OnGrowthExceed: OnGrowthExceedWarn}, "", "cpu limit '3' grows too much from the previous value '1'"},
+		{"new containers are not checked", newNamedContainer("sidecar", "3", "3"), ResourceConfiguration{MaxGrowthFactor: resource.MustParse("2")}, "", ""},
+		{"missing values are not checked", newNamedContainer("app", "", "3"), ResourceConfiguration{MaxGrowthDelta: resource.MustParse("1")}, "cpu request '3'", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := (&Settings{Cpu: &test.resourceConfig}).withOldPod(oldPod)
+			warnings, err := validateContainerGrowth(test.container, settings)
+			if test.expectedErrorMsg == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if test.expectedErrorMsg != "" && (err == nil || !strings.Contains(err.Error(), test.expectedErrorMsg)) {
+				t.Errorf("invalid error. Expected the string '%s', got %v", test.expectedErrorMsg, err)
+			}
+			if test.expectedWarning == "" && len(warnings) > 0 {
+				t.Errorf("unexpected warnings: %v", warnings)
+			}
+			if test.expectedWarning != "" && (len(warnings) != 1 || !strings.Contains(warnings[0].Error(), test.expectedWarning)) {
+				t.Errorf("invalid warnings. Expected the string '%s', got %v", test.expectedWarning, warnings)
+			}
+		})
+	}
+}
+
+func TestGrowthOnUpdate(t *testing.T) {
+	rawSettings := `{"cpu": {"maxLimit": "4", "defaultRequest": "1m", "defaultLimit": "1m", "maxGrowthFactor": "2"}}`
+	// The cpu values of the old pod are at least ten times lower
+	shrinkOldObject := func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+		object := map[string]interface{}{}
+		if err := json.Unmarshal(request.Object, &object); err != nil {
+			t.Fatalf("cannot parse the object: %v", err)
+		}
+		for _, container := range object["spec"].(map[string]interface{})["containers"].([]interface{}) {
+			resources := container.(map[string]interface{})["resources"].(map[string]interface{})
+			resources["limits"].(map[string]interface{})["cpu"] = "200u"
+			resources["requests"].(map[string]interface{})["cpu"] = "200u"
+		}
+		oldObject, err := json.Marshal(object)
+		if err != nil {
+			t.Fatalf("cannot marshal the old object: %v", err)
+		}
+		request.OldObject = oldObject
+	}
+
+	response := validateTestRequest(t, "test_data/pod_exceeding_range.json", rawSettings, func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+		shrinkOldObject(request)
+		request.Operation = "UPDATE"
+	})
+	if response.Accepted {
+		t.Fatal("the request should be rejected")
+	}
+	if !strings.Contains(*response.Message, "spec.containers[1].resources.limits.cpu (container 'mycontainer'): cpu limit '2m' grows too much from the previous value '200u'") {
+		t.Errorf("invalid message: %s", *response.Message)
+	}
+
+	response = validateTestRequest(t, "test_data/pod_exceeding_range.json", rawSettings, func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+		shrinkOldObject(request)
+		request.Operation = "CREATE"
+	})
+	if !response.Accepted {
+		t.Errorf("the growth should not be checked on create: %v", *response.Message)
+	}
+}
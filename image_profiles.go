@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ImageProfile defines resource configurations merged over the ones of the
+// pod for the containers using the given images. Images are patterns with
+// the same syntax of the ignoreImages entries
+type ImageProfile struct {
+	Images []string `json:"images"`
+	ResourceOverrides
+}
+
+func (p *ImageProfile) valid() error {
+	if len(p.Images) == 0 {
+		return fmt.Errorf("at least one image must be defined")
+	}
+	if err := validImagePatterns(p.Images); err != nil {
+		return err
+	}
+	if p.Qos != nil {
+		// The QoS class is computed for the whole pod
+		return fmt.Errorf("the QoS configuration cannot be defined by image profiles")
+	}
+	return p.ResourceOverrides.valid()
+}
+
+// podSettingsCandidates returns all the settings that can be used for a pod:
+// the global settings, and the global settings merged with each namespace
+// override and each profile
+func (s *Settings) podSettingsCandidates() []*Settings {
+	base := *s
+	base.Overrides, base.Profiles, base.ImageProfiles, base.ContainerProfiles = nil, nil, nil, nil
+	namespaceSettings := []*Settings{&base}
+	for i := range s.Overrides {
+		namespaceSettings = append(namespaceSettings, base.withOverrides(&s.Overrides[i].ResourceOverrides))
+	}
+	candidates := namespaceSettings
+	for _, settings := range namespaceSettings {
+		for i := range s.Profiles {
+			candidates = append(candidates, settings.withOverrides(&s.Profiles[i].ResourceOverrides))
+		}
+	}
+	return candidates
+}
+
+// validImageProfiles checks the image profiles, and the settings resulting
+// from merging each one of them over all the settings that can be used for
+// a pod
+func (s *Settings) validImageProfiles() error {
+	if len(s.ImageProfiles) == 0 {
+		return nil
+	}
+	candidates := s.podSettingsCandidates()
+	for i := range s.ImageProfiles {
+		imageProfile := &s.ImageProfiles[i]
+		err := imageProfile.valid()
+		for _, settings := range candidates {
+			if err != nil {
+				break
+			}
+			err = settings.withOverrides(&imageProfile.ResourceOverrides).Valid()
+		}
+		if err != nil {
+			return errors.Join(fmt.Errorf("invalid imageProfiles[%d] settings", i), err)
+		}
+	}
+	return nil
+}
+
+// forImage returns the settings used for the containers using the given
+// image. The first image profile matching the image is merged over the
+// settings
+func (s *Settings) forImage(image string) *Settings {
+	for i := range s.ImageProfiles {
+		if imageMatchesAny(image, s.ImageProfiles[i].Images) {
+			settings := s.withOverrides(&s.ImageProfiles[i].ResourceOverrides)
+			settings.ImageProfiles = nil
+			return settings
+		}
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	apimachinery_pkg_api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+func TestImageProfiles(t *testing.T) {
+	rawSettings := []byte(`{
+		"memory": {"maxLimit": "1Gi", "defaultLimit": "512Mi", "defaultRequest": "256Mi"},
+		"ignoreImages": ["registry.k8s.io/pause:*"],
+		"imageProfiles": [
+			{"images": ["ghcr.io/example/*-jvm:*", "eclipse-temurin:*"], "memory": {"maxLimit": "8Gi", "defaultLimit": "4Gi"}}
+		]
+	}`)
+	settings := Settings{}
+	if err := json.Unmarshal(rawSettings, &settings); err != nil {
+		t.Fatalf("cannot parse settings: %v", err)
+	}
+	if err := settings.Valid(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	newContainer := func(image string) *corev1.Container {
+		return &corev1.Container{Image: image}
+	}
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{
+			newContainer("ghcr.io/example/orders-jvm:v1"),
+			newContainer("nginx:1.25"),
+			newContainer("registry.k8s.io/pause:3.9"),
+		},
+	}
+	mutated, _, err := validatePodSpec(podSpec, nil, "spec", &settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mutated {
+		t.Fatal("the pod should be mutated")
+	}
+	expected := []struct {
+		limit   string
+		request string
+	}{
+		{"4Gi", "256Mi"},
+		{"512Mi", "256Mi"},
+	}
+	for i, values := range expected {
+		resources := podSpec.Containers[i].Resources
+		if limit := resources.Limits["memory"]; limit == nil || string(*limit) != values.limit {
+			t.Errorf("container %d: invalid memory limit. Expected %s, got %v", i, values.limit, limit)
+		}
+		if request := resources.Requests["memory"]; request == nil || string(*request) != values.request {
+			t.Errorf("container %d: invalid memory request. Expected %s, got %v", i, values.request, request)
+		}
+	}
+	if podSpec.Containers[2].Resources != nil {
+		t.Errorf("ignored images should not be mutated")
+	}
+
+	sixGi := apimachinery_pkg_api_resource.Quantity("6Gi")
+	nineGi := apimachinery_pkg_api_resource.Quantity("9Gi")
+	podSpec = &corev1.PodSpec{
+		Containers: []*corev1.Container{
+			{
+				Image:     "eclipse-temurin:21",
+				Resources: &corev1.ResourceRequirements{Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"memory": &sixGi}},
+			},
+			{
+				Image:     "eclipse-temurin:17",
+				Resources: &corev1.ResourceRequirements{Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"memory": &nineGi}},
+			},
+			{
+				Image:     "nginx:1.25",
+				Resources: &corev1.ResourceRequirements{Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"memory": &sixGi}},
+			},
+		},
+	}
+	_, _, err = validatePodSpec(podSpec, nil, "spec", &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	expectedErrors := []string{
+		"spec.containers[1].resources.limits.memory (container ''): memory limit '9Gi' exceeds the max allowed value '8Gi'",
+		"spec.containers[2].resources.limits.memory (container ''): memory limit '6Gi' exceeds the max allowed value '1Gi'",
+	}
+	if actualErrors := strings.Split(err.Error(), "\n"); strings.Join(actualErrors, "\n") != strings.Join(expectedErrors, "\n") {
+		t.Errorf("invalid errors. Expected:\n%s\nGot:\n%s", strings.Join(expectedErrors, "\n"), err.Error())
+	}
+}
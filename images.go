@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultRegistry = "docker.io"
+	defaultTag      = "latest"
+	// Prefix of the ignoreImages entries defining a regular expression
+	imageRegexPrefix = "regex:"
+)
+
+// imageReference is a container image reference split in its parts
+type imageReference struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+func (r imageReference) String() string {
+	reference := r.registry + "/" + r.repository
+	if r.tag != "" {
+		reference += ":" + r.tag
+	}
+	if r.digest != "" {
+		reference += "@" + r.digest
+	}
+	return reference
+}
+
+// isRegistry returns true when the first component of an image reference is
+// a registry host. As done by the container runtimes, it must contain a dot
+// or a port, or be localhost. Components with glob characters are handled as
+// registries as well, so patterns like "*/pause" match any registry
+func isRegistry(component string) bool {
+	return strings.ContainsAny(component, ".:*?[") || component == "localhost"
+}
+
+// splitImageReference splits the reference in its parts, without adding the
+// default values
+func splitImageReference(image string) imageReference {
+	reference := imageReference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, reference.digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i+1:], "/") {
+		name, reference.tag = name[:i], name[i+1:]
+	}
+	if i := strings.Index(name, "/"); i >= 0 && isRegistry(name[:i]) {
+		reference.registry, name = name[:i], name[i+1:]
+	}
+	reference.repository = name
+	return reference
+}
+
+// setDefaultRegistry sets the registry used when the reference does not
+// define it. As done by the container runtimes, the default registry is
+// docker.io, where the official images are in the library namespace
+func (r *imageReference) setDefaultRegistry() {
+	if r.registry == "" || r.registry == "index.docker.io" {
+		r.registry = defaultRegistry
+	}
+	if r.registry == defaultRegistry && !strings.Contains(r.repository, "/") {
+		r.repository = "library/" + r.repository
+	}
+}
+
+// normalizeImageReference returns the fully qualified reference of the image.
+// The default tag is latest, unless a digest is defined. For example, "nginx"
+// is normalized to "docker.io/library/nginx:latest"
+func normalizeImageReference(image string) imageReference {
+	reference := splitImageReference(image)
+	reference.setDefaultRegistry()
+	if reference.tag == "" && reference.digest == "" {
+		reference.tag = defaultTag
+	}
+	return reference
+}
+
+// imagePattern is an ignoreImages entry. The registry, the repository and the
+// tag are glob patterns matched separately
+type imagePattern struct {
+	imageReference
+	regex *regexp.Regexp
+	raw   string
+}
+
+func parseImagePattern(pattern string) (*imagePattern, error) {
+	if strings.HasPrefix(pattern, imageRegexPrefix) {
+		regex, err := regexp.Compile(strings.TrimPrefix(pattern, imageRegexPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid image regular expression '%s': %w", pattern, err)
+		}
+		return &imagePattern{regex: regex, raw: pattern}, nil
+	}
+	reference := splitImageReference(pattern)
+	reference.setDefaultRegistry()
+	if reference.tag == "" && reference.digest == "" {
+		// Patterns like "registry.k8s.io/pause*" match any tag
+		reference.tag = defaultTag
+		if strings.HasSuffix(reference.repository, "*") {
+			reference.tag = "*"
+		}
+	}
+	for _, glob := range []string{reference.registry, reference.repository, reference.tag} {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid image pattern '%s': %w", pattern, err)
+		}
+	}
+	return &imagePattern{imageReference: reference, raw: pattern}, nil
+}
+
+// parsedImagePattern is the result of the parsing of an image pattern
+type parsedImagePattern struct {
+	pattern *imagePattern
+	err     error
+}
+
+// imagePatterns caches the parsed image patterns, indexed by the raw pattern.
+// The settings are sent with every request, so the patterns, and their
+// regular expressions, are parsed only the first time they are used
+var imagePatterns sync.Map
+
+// cachedImagePattern returns the parsed image pattern, parsing it only the
+// first time it is requested
+func cachedImagePattern(pattern string) (*imagePattern, error) {
+	if parsed, found := imagePatterns.Load(pattern); found {
+		return parsed.(parsedImagePattern).pattern, parsed.(parsedImagePattern).err
+	}
+	imagePattern, err := parseImagePattern(pattern)
+	imagePatterns.Store(pattern, parsedImagePattern{pattern: imagePattern, err: err})
+	return imagePattern, err
+}
+
+func globMatches(pattern, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// matches returns true when the image matches the pattern. The regular
+// expressions are matched against the normalized image reference. When the
+// pattern defines a digest, the image must have the same digest, and the tag
+// is checked only when the pattern defines it as well. Images referenced only
+// by digest do not have a tag, so they match only patterns like "nginx:*".
+// For backward compatibility, patterns with a trailing "*" also match the
+// images starting with the same prefix.
+func (p *imagePattern) matches(image string) bool {
+	reference := normalizeImageReference(image)
+	if p.regex != nil {
+		return p.regex.MatchString(reference.String())
+	}
+	if strings.HasSuffix(p.raw, "*") && strings.HasPrefix(image, strings.TrimSuffix(p.raw, "*")) {
+		return true
+	}
+	if !globMatches(p.registry, reference.registry) {
+		return false
+	}
+	repositoryMatches := globMatches(p.repository, reference.repository)
+	if !repositoryMatches && reference.registry == defaultRegistry {
+		// Allow patterns like "*/nginx" to match the docker.io official images
+		repositoryMatches = globMatches(p.repository, strings.TrimPrefix(reference.repository, "library/"))
+	}
+	if !repositoryMatches {
+		return false
+	}
+	if p.digest != "" {
+		if p.digest != reference.digest {
+			return false
+		}
+		return p.tag == "" || globMatches(p.tag, reference.tag)
+	}
+	return globMatches(p.tag, reference.tag)
+}
+
+// validImagePatterns checks that all the given image patterns are valid
+func validImagePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := cachedImagePattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageMatchesAny returns true when the image matches one of the patterns.
+// Invalid patterns are rejected by the settings validation, so they are
+// ignored here
+func imageMatchesAny(image string, patterns []string) bool {
+	for _, pattern := range patterns {
+		imagePattern, err := cachedImagePattern(pattern)
+		if err == nil && imagePattern.matches(image) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNormalizeImageReference(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"nginx", "docker.io/library/nginx:latest"},
+		{"nginx:1.25", "docker.io/library/nginx:1.25"},
+		{"myuser/app", "docker.io/myuser/app:latest"},
+		{"index.docker.io/nginx", "docker.io/library/nginx:latest"},
+		{"registry.k8s.io/pause:3.9", "registry.k8s.io/pause:3.9"},
+		{"localhost/app", "localhost/app:latest"},
+		{"localhost:5000/team/app:v1", "localhost:5000/team/app:v1"},
+		{"nginx@sha256:0123456789abcdef", "docker.io/library/nginx@sha256:0123456789abcdef"},
+		{"ghcr.io/org/app:v1@sha256:0123456789abcdef", "ghcr.io/org/app:v1@sha256:0123456789abcdef"},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			if normalized := normalizeImageReference(test.image).String(); normalized != test.expected {
+				t.Errorf("invalid normalized reference. Expected '%s', got '%s'", test.expected, normalized)
+			}
+		})
+	}
+}
+
+func TestCachedImagePattern(t *testing.T) {
+	first, err := cachedImagePattern("regex:^ghcr\\.io/org/.*$")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := cachedImagePattern("regex:^ghcr\\.io/org/.*$")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second {
+		t.Error("the image pattern was parsed again")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cachedImagePattern("regex:("); err == nil {
+			t.Error("the invalid image pattern was accepted")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// How the objects of the kinds not supported by the policy are handled
+const (
+	// Reject them
+	UnsupportedKindsReject = "reject"
+	// Accept them
+	UnsupportedKindsAccept = "accept"
+	// Accept them, returning a warning
+	UnsupportedKindsWarn = "warn"
+)
+
+// Audit annotation recording why the object has not been validated because
+// its kind is not supported
+const unsupportedKindAuditAnnotation = "unsupported-kind"
+
+// The workload kinds whose PodSpec is known by the Kubewarden SDK, indexed by
+// group. The custom resources of other groups can have the same kind
+var builtinKinds = map[string][]string{
+	"":      {"Pod", "ReplicationController"},
+	"apps":  {"Deployment", "ReplicaSet", "StatefulSet", "DaemonSet"},
+	"batch": {"Job", "CronJob"},
+}
+
+// unsupportedKindsAction returns how the objects of the kinds not supported
+// by the policy are handled
+func (s *Settings) unsupportedKindsAction() string {
+	if s.UnsupportedKinds == "" {
+		return UnsupportedKindsReject
+	}
+	return s.UnsupportedKinds
+}
+
+// supportedKind returns true when the kind is a workload known by the policy,
+// or a custom resource configured in the settings
+func (s *Settings) supportedKind(gvk kubewarden_protocol.GroupVersionKind) bool {
+	for i := range s.CustomResources {
+		if s.CustomResources[i].matches(gvk) {
+			return true
+		}
+	}
+	return slices.Contains(builtinKinds[gvk.Group], gvk.Kind)
+}
+
+// builtinKindNames returns the sorted names of the workload kinds known by
+// the Kubewarden SDK, prefixed by their group
+func builtinKindNames() []string {
+	names := []string{}
+	for group, kinds := range builtinKinds {
+		for _, kind := range kinds {
+			if group == "" {
+				names = append(names, kind)
+			} else {
+				names = append(names, fmt.Sprintf("%s/%s", group, kind))
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// unsupportedKindError returns the error describing an object which is not a
+// workload known by the policy
+func unsupportedKindError(gvk kubewarden_protocol.GroupVersionKind) error {
+	return fmt.Errorf("the %s kind is not a workload supported by the policy. Supported kinds are: %s, and the customResources of the settings", kindName(gvk), strings.Join(builtinKindNames(), ", "))
+}
+
+// malformedObjectError returns the error describing an object of a supported
+// kind whose pods cannot be read
+func malformedObjectError(gvk kubewarden_protocol.GroupVersionKind, err error) error {
+	return fmt.Errorf("malformed %s object: %w", kindName(gvk), err)
+}
+
+// kindName returns the kind prefixed by its group and its version, like
+// "apps/v1/Deployment"
+func kindName(gvk kubewarden_protocol.GroupVersionKind) string {
+	if gvk.Group == "" {
+		return fmt.Sprintf("%s/%s", gvk.Version, gvk.Kind)
+	}
+	return fmt.Sprintf("%s/%s/%s", gvk.Group, gvk.Version, gvk.Kind)
+}
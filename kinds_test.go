@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestUnsupportedKinds(t *testing.T) {
+	service := kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Service"}
+	unsupportedMessage := "the v1/Service kind is not a workload supported by the policy"
+	tests := []struct {
+		name               string
+		action             string
+		expectedAccepted   bool
+		expectedWarning    bool
+		expectedAnnotation bool
+	}{
+		{"rejected by default", "", false, false, false},
+		{"rejected", UnsupportedKindsReject, false, false, false},
+		{"accepted", UnsupportedKindsAccept, true, false, true},
+		{"accepted with a warning", UnsupportedKindsWarn, true, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rawSettings := fmt.Sprintf(`{"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"}, "unsupportedKinds": "%s"}`, test.action)
+			response := validateTestRequest(t, "test_data/pod_without_resources.json", rawSettings, func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+				request.Kind = service
+				request.Object = []byte(`{"apiVersion": "v1", "kind": "Service", "spec": {"ports": [{"port": 80}]}}`)
+			})
+			if response.Accepted != test.expectedAccepted {
+				t.Fatalf("expected accepted to be %t, got %t", test.expectedAccepted, response.Accepted)
+			}
+			if !test.expectedAccepted && !strings.Contains(*response.Message, unsupportedMessage) {
+				t.Errorf("invalid message: %s", *response.Message)
+			}
+			if test.expectedWarning != (len(response.Warnings) == 1 && strings.Contains(response.Warnings[0], unsupportedMessage)) {
+				t.Errorf("invalid warnings: %v", response.Warnings)
+			}
+			if test.expectedAnnotation != strings.Contains(response.AuditAnnotations[unsupportedKindAuditAnnotation], unsupportedMessage) {
+				t.Errorf("invalid audit annotations: %v", response.AuditAnnotations)
+			}
+			if response.MutatedObject != nil {
+				t.Errorf("unexpected mutation: %v", response.MutatedObject)
+			}
+		})
+	}
+}
+
+func TestMalformedObjects(t *testing.T) {
+	rawSettings := `{
+		"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"},
+		"customResources": [{"group": "argoproj.io", "kind": "Rollout", "podSpecPaths": ["spec.template.spec"]}],
+		"unsupportedKinds": "accept"
+	}`
+	tests := []struct {
+		name             string
+		gvk              kubewarden_protocol.GroupVersionKind
+		object           string
+		expectedErrorMsg string
+	}{
+		{"deployment without pod template", kubewarden_protocol.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, `{"spec": {"replicas": 1}}`, "malformed apps/v1/Deployment object: cannot find the 'spec.template.spec' field"},
+		{"pod with invalid containers", kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"}, `{"spec": {"containers": "nginx"}}`, "malformed v1/Pod object"},
+		{"custom resource with an invalid PodSpec", kubewarden_protocol.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}, `{"spec": {"template": {"spec": {"containers": {}}}}}`, "malformed argoproj.io/v1alpha1/Rollout object: invalid PodSpec at 'spec.template.spec'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validateTestRequest(t, "test_data/pod_without_resources.json", rawSettings, func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+				request.Kind = test.gvk
+				request.Object = []byte(test.object)
+			})
+			// Malformed objects are rejected, regardless of the unsupportedKinds
+			// action
+			if response.Accepted {
+				t.Fatal("the request should be rejected")
+			}
+			if !strings.Contains(*response.Message, test.expectedErrorMsg) {
+				t.Errorf("invalid message. Expected the string '%s', got %s", test.expectedErrorMsg, *response.Message)
+			}
+		})
+	}
+}
+
+func TestBuiltinKindsOfOtherGroups(t *testing.T) {
+	rawSettings := `{"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"}, "unsupportedKinds": "warn"}`
+	tests := []struct {
+		name string
+		gvk  kubewarden_protocol.GroupVersionKind
+	}{
+		{"deployment of another group", kubewarden_protocol.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Deployment"}},
+		{"job of another group", kubewarden_protocol.GroupVersionKind{Group: "batch.volcano.sh", Version: "v1alpha1", Kind: "Job"}},
+		{"pod of another group", kubewarden_protocol.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Pod"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validateTestRequest(t, "test_data/pod_without_resources.json", rawSettings, func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+				request.Kind = test.gvk
+				request.Object = []byte(`{"spec": {"tasks": [{"replicas": 1}]}}`)
+			})
+			// The object is handled as an unsupported kind, not as a malformed
+			// workload
+			if !response.Accepted {
+				t.Fatalf("the request should be accepted: %v", *response.Message)
+			}
+			if len(response.Warnings) != 1 || !strings.Contains(response.Warnings[0], "is not a workload supported by the policy. Supported kinds are: Pod, ReplicationController, apps/DaemonSet") {
+				t.Errorf("invalid warnings: %v", response.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+// Object is the raw representation of the object under validation. It keeps
+// all the fields of the original object, including the ones not available in
+// the types used by the policy, like the pod level resources.
+type Object map[string]interface{}
+
+func newObject(raw []byte) (Object, error) {
+	object := Object{}
+	if err := unmarshalRaw(raw, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// unmarshalRaw decodes the payload in its raw representation. The numbers are
+// kept as json.Number, so the integers not representable by a float64 are not
+// changed when the object is sent back
+func unmarshalRaw(payload []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+// lookup returns the map found at the given path. The path is a list of field
+// names separated by dots, like "spec.template.spec"
+func (o Object) lookup(path string) (map[string]interface{}, error) {
+	current := map[string]interface{}(o)
+	for _, field := range strings.Split(path, ".") {
+		next, ok := current[field].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot find the '%s' field", path)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// lookupParent returns the map containing the last field of the given path,
+// and the name of the field
+func (o Object) lookupParent(path string) (map[string]interface{}, string, error) {
+	index := strings.LastIndex(path, ".")
+	if index < 0 {
+		return o, path, nil
+	}
+	parent, err := o.lookup(path[:index])
+	return parent, path[index+1:], err
+}
+
+// podSpec returns the PodSpec found at the given path, or nil when the object
+// does not define it
+func (o Object) podSpec(path string) (*corev1.PodSpec, error) {
+	rawPodSpec, err := o.lookup(path)
+	if err != nil {
+		return nil, nil
+	}
+	payload, err := json.Marshal(rawPodSpec)
+	if err != nil {
+		return nil, err
+	}
+	podSpec := &corev1.PodSpec{}
+	if err := json.Unmarshal(payload, podSpec); err != nil {
+		return nil, fmt.Errorf("invalid PodSpec at '%s': %w", path, err)
+	}
+	return podSpec, nil
+}
+
+// containerList returns the list of containers found at the given path, or
+// nil when the object does not define it. The resources of the containers are
+// read from the given field
+func (o Object) containerList(path, resourcesField string) ([]*corev1.Container, error) {
+	parent, field, err := o.lookupParent(path)
+	if err != nil {
+		return nil, nil
+	}
+	rawContainers, found := parent[field]
+	if !found || rawContainers == nil {
+		return nil, nil
+	}
+	if resourcesField != defaultResourcesField {
+		rawContainers = renameContainersField(rawContainers, resourcesField, defaultResourcesField)
+	}
+	payload, err := json.Marshal(rawContainers)
+	if err != nil {
+		return nil, err
+	}
+	containers := []*corev1.Container{}
+	if err := json.Unmarshal(payload, &containers); err != nil {
+		return nil, fmt.Errorf("invalid list of containers at '%s': %w", path, err)
+	}
+	return containers, nil
+}
+
+// renameContainersField returns a copy of the list of containers where the
+// given field is renamed. The other values are returned as they are
+func renameContainersField(rawContainers interface{}, from, to string) interface{} {
+	containers, ok := rawContainers.([]interface{})
+	if !ok {
+		return rawContainers
+	}
+	renamed := []interface{}{}
+	for _, container := range containers {
+		containerObject, ok := container.(map[string]interface{})
+		if !ok {
+			renamed = append(renamed, container)
+			continue
+		}
+		renamedContainer := map[string]interface{}{}
+		for key, value := range containerObject {
+			switch key {
+			case from:
+				renamedContainer[to] = value
+			case to:
+				// The field is replaced by the renamed one
+			default:
+				renamedContainer[key] = value
+			}
+		}
+		renamed = append(renamed, renamedContainer)
+	}
+	return renamed
+}
+
+// podLevelResources returns the pod level resources (the resources field of
+// the PodSpec), or nil when they are not defined
+func (o Object) podLevelResources(podSpecPath string) (*corev1.ResourceRequirements, error) {
+	podSpec, err := o.lookup(podSpecPath)
+	if err != nil {
+		return nil, err
+	}
+	rawResources, found := podSpec["resources"]
+	if !found || rawResources == nil {
+		return nil, nil
+	}
+	payload, err := json.Marshal(rawResources)
+	if err != nil {
+		return nil, err
+	}
+	resources := &corev1.ResourceRequirements{}
+	if err := json.Unmarshal(payload, resources); err != nil {
+		return nil, fmt.Errorf("invalid pod level resources: %w", err)
+	}
+	return resources, nil
+}
+
+// setContainerResources replaces the resources of the containers found in
+// the given list of the PodSpec, setting the given field of the containers.
+// All the other fields are left untouched
+func setContainerResources(podSpec map[string]interface{}, listName, resourcesField string, resources []*corev1.ResourceRequirements) error {
+	containers, _ := podSpec[listName].([]interface{})
+	if len(containers) != len(resources) {
+		return fmt.Errorf("unexpected number of %s", listName)
+	}
+	for i, container := range containers {
+		containerObject, ok := container.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid %s[%d] definition", listName, i)
+		}
+		if resources[i] == nil {
+			continue
+		}
+		payload, err := json.Marshal(resources[i])
+		if err != nil {
+			return err
+		}
+		var resourcesObject map[string]interface{}
+		if err := unmarshalRaw(payload, &resourcesObject); err != nil {
+			return err
+		}
+		containerObject[resourcesField] = resourcesObject
+	}
+	return nil
+}
+
+// setPodSpecResources mutates the object, setting the resources of the
+// containers found in the passed PodSpec. Only the container resources are
+// changed. Therefore, the fields unknown to the policy are preserved.
+func (o Object) setPodSpecResources(podSpecPath string, pod *corev1.PodSpec) error {
+	podSpecObject, err := o.lookup(podSpecPath)
+	if err != nil {
+		return err
+	}
+	containersResources := []*corev1.ResourceRequirements{}
+	for _, container := range pod.Containers {
+		containersResources = append(containersResources, container.Resources)
+	}
+	initContainersResources := []*corev1.ResourceRequirements{}
+	for _, container := range pod.InitContainers {
+		initContainersResources = append(initContainersResources, container.Resources)
+	}
+	ephemeralContainersResources := []*corev1.ResourceRequirements{}
+	for _, container := range pod.EphemeralContainers {
+		ephemeralContainersResources = append(ephemeralContainersResources, container.Resources)
+	}
+	if err := setContainerResources(podSpecObject, "containers", defaultResourcesField, containersResources); err != nil {
+		return err
+	}
+	if err := setContainerResources(podSpecObject, "initContainers", defaultResourcesField, initContainersResources); err != nil {
+		return err
+	}
+	return setContainerResources(podSpecObject, "ephemeralContainers", defaultResourcesField, ephemeralContainersResources)
+}
+
+// setContainerListResources mutates the object, setting the resources of the
+// list of containers found at the given path in the given field. Only the
+// container resources are changed
+func (o Object) setContainerListResources(path, resourcesField string, containers []*corev1.Container) error {
+	parent, field, err := o.lookupParent(path)
+	if err != nil {
+		return err
+	}
+	resources := []*corev1.ResourceRequirements{}
+	for _, container := range containers {
+		resources = append(resources, container.Resources)
+	}
+	return setContainerResources(parent, field, resourcesField, resources)
+}
+
+// labels returns the labels of the object
+func (o Object) labels() map[string]string {
+	metadata, err := o.lookup("metadata")
+	if err != nil {
+		return map[string]string{}
+	}
+	return stringMap(metadata["labels"])
+}
+
+// annotations returns the annotations of the object
+func (o Object) annotations() map[string]string {
+	metadata, err := o.lookup("metadata")
+	if err != nil {
+		return map[string]string{}
+	}
+	return stringMap(metadata["annotations"])
+}
+
+// podMetadata returns the labels and the annotations of the pod, or of the
+// pod template, whose PodSpec is found at the given path. The path must end
+// with the spec field, the metadata field is its sibling
+func (o Object) podMetadata(podSpecPath string) (map[string]string, map[string]string) {
+	metadataPath := strings.TrimSuffix(podSpecPath, "spec") + "metadata"
+	metadata, err := o.lookup(metadataPath)
+	if err != nil {
+		return map[string]string{}, map[string]string{}
+	}
+	return stringMap(metadata["labels"]), stringMap(metadata["annotations"])
+}
+
+func stringMap(value interface{}) map[string]string {
+	result := map[string]string{}
+	values, _ := value.(map[string]interface{})
+	for key, value := range values {
+		if str, ok := value.(string); ok {
+			result[key] = str
+		}
+	}
+	return result
+}
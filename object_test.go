@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	apimachinery_pkg_api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const podWithPodLevelResources = `{
+	"apiVersion": "v1",
+	"kind": "Pod",
+	"metadata": {"name": "nginx"},
+	"spec": {
+		"resources": {"limits": {"cpu": "2"}, "requests": {"cpu": "1"}},
+		"containers": [
+			{"name": "nginx", "image": "nginx", "unknownField": "value"},
+			{"name": "sidecar", "image": "busybox", "resources": {"limits": {"cpu": "1"}}}
+		]
+	}
+}`
+
+func TestPodLevelResources(t *testing.T) {
+	object, err := newObject([]byte(podWithPodLevelResources))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	podResources, err := object.podLevelResources("spec")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	limit := apimachinery_pkg_api_resource.Quantity("2")
+	request := apimachinery_pkg_api_resource.Quantity("1")
+	expected := &corev1.ResourceRequirements{
+		Limits:   map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &limit},
+		Requests: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &request},
+	}
+	if diff := cmp.Diff(expected, podResources); diff != "" {
+		t.Errorf("invalid pod level resources: %s", diff)
+	}
+
+	podResources, err = object.podLevelResources("spec.template.spec")
+	if err == nil {
+		t.Errorf("expected error, got resources %v", podResources)
+	}
+
+	object, err = newObject([]byte(`{"spec": {"containers": []}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	podResources, err = object.podLevelResources("spec")
+	if err != nil || podResources != nil {
+		t.Errorf("expected no pod level resources, got %v, error: %v", podResources, err)
+	}
+}
+
+func TestMutationPreservesUnknownFields(t *testing.T) {
+	object, err := newObject([]byte(podWithPodLevelResources))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	limit := apimachinery_pkg_api_resource.Quantity("500m")
+	pod := &corev1.PodSpec{
+		Containers: []*corev1.Container{
+			{
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &limit},
+				},
+			},
+			{},
+		},
+	}
+	if err := object.setPodSpecResources("spec", pod); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	payload, err := mutateRequest(object, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response := kubewarden_protocol.ValidationResponse{}
+	if err := json.Unmarshal(payload, &response); err != nil {
+		t.Fatalf("cannot unmarshal response: %v", err)
+	}
+	if !response.Accepted || response.MutatedObject == nil {
+		t.Fatalf("expected the request to be accepted and mutated")
+	}
+	mutated := response.MutatedObject.(map[string]interface{})
+	spec := mutated["spec"].(map[string]interface{})
+	expectedPodResources := map[string]interface{}{
+		"limits":   map[string]interface{}{"cpu": "2"},
+		"requests": map[string]interface{}{"cpu": "1"},
+	}
+	if diff := cmp.Diff(expectedPodResources, spec["resources"]); diff != "" {
+		t.Errorf("pod level resources changed: %s", diff)
+	}
+	containers := spec["containers"].([]interface{})
+	expectedContainer := map[string]interface{}{
+		"name":         "nginx",
+		"image":        "nginx",
+		"unknownField": "value",
+		"resources":    map[string]interface{}{"limits": map[string]interface{}{"cpu": "500m"}},
+	}
+	if diff := cmp.Diff(expectedContainer, containers[0]); diff != "" {
+		t.Errorf("invalid mutated container: %s", diff)
+	}
+	expectedSidecar := map[string]interface{}{
+		"name":      "sidecar",
+		"image":     "busybox",
+		"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1"}},
+	}
+	if diff := cmp.Diff(expectedSidecar, containers[1]); diff != "" {
+		t.Errorf("container without changes was modified: %s", diff)
+	}
+}
+
+func TestMutationPreservesLargeIntegers(t *testing.T) {
+	object, err := newObject([]byte(`{
+		"apiVersion": "v1",
+		"kind": "Pod",
+		"metadata": {"name": "nginx"},
+		"spec": {
+			"securityContext": {"runAsUser": 12345678901234567},
+			"containers": [{"name": "nginx", "image": "nginx"}]
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod, err := object.podSpec("spec")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.SecurityContext.RunAsUser != 12345678901234567 {
+		t.Errorf("invalid runAsUser: %d", pod.SecurityContext.RunAsUser)
+	}
+	limit := apimachinery_pkg_api_resource.Quantity("500m")
+	pod.Containers[0].Resources = &corev1.ResourceRequirements{
+		Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &limit},
+	}
+	if err := object.setPodSpecResources("spec", pod); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	payload, err := mutateRequest(object, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(payload), `"runAsUser":12345678901234567`) {
+		t.Errorf("the large integer field was changed: %s", payload)
+	}
+}
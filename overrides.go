@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+)
+
+// ResourceOverrides defines resource configurations merged over the global
+// ones. Only the values defined by the overrides replace the global values
+type ResourceOverrides struct {
+	Cpu       *ResourceConfiguration            `json:"cpu,omitempty"`
+	Memory    *ResourceConfiguration            `json:"memory,omitempty"`
+	Resources map[string]*ResourceConfiguration `json:"resources,omitempty"`
+	// Replaces the QoS configuration when defined
+	Qos *QosConfiguration `json:"qos,omitempty"`
+}
+
+// NamespaceOverride defines the resource configurations used for the objects
+// created in the given namespaces. Namespaces can be names or glob patterns
+type NamespaceOverride struct {
+	Namespaces []string `json:"namespaces"`
+	ResourceOverrides
+}
+
+// resourceConfiguration returns the configuration of the given resource
+// defined by the overrides, or nil when it is not defined
+func (o *ResourceOverrides) resourceConfiguration(resourceName string) *ResourceConfiguration {
+	if resourceName == "cpu" && o.Cpu != nil {
+		return o.Cpu
+	}
+	if resourceName == "memory" && o.Memory != nil {
+		return o.Memory
+	}
+	return o.Resources[resourceName]
+}
+
+func (o *ResourceOverrides) resourceNames() []string {
+	settings := Settings{Cpu: o.Cpu, Memory: o.Memory, Resources: o.Resources}
+	return settings.resourceNames()
+}
+
+func (o *ResourceOverrides) valid() error {
+	if o.Cpu != nil && o.Resources["cpu"] != nil {
+		return fmt.Errorf("cpu settings cannot be defined in both the cpu and the resources fields")
+	}
+	if o.Memory != nil && o.Resources["memory"] != nil {
+		return fmt.Errorf("memory settings cannot be defined in both the memory and the resources fields")
+	}
+	return nil
+}
+
+// quantities returns all the quantities of the configuration, so they can
+// be merged
+func (r *ResourceConfiguration) quantities() []*resource.Quantity {
+	return []*resource.Quantity{
+		&r.MaxLimit, &r.MinLimit, &r.MinRequest, &r.MaxRequest,
+		&r.MaxLimitRequestRatio, &r.MinLimitRequestRatio,
+		&r.DefaultRequest, &r.DefaultLimit,
+		&r.RequestFactor, &r.LimitFactor,
+		&r.MaxGrowthFactor, &r.MaxGrowthDelta,
+	}
+}
+
+// mergeResourceConfiguration returns a new configuration where the values
+// defined in the override replace the base values. The ignoreValues field
+// of the override is used when it is true, or when the override defines any
+// value. The limitMode, onExceed, onGrowthExceed and derivation fields of the
+// override are used when they are defined.
+func mergeResourceConfiguration(base, override *ResourceConfiguration) *ResourceConfiguration {
+	if base == nil {
+		merged := *override
+		return &merged
+	}
+	merged := *base
+	if override == nil {
+		return &merged
+	}
+	definesValues := false
+	mergedQuantities := merged.quantities()
+	for i, quantity := range override.quantities() {
+		if !quantity.IsZero() {
+			*mergedQuantities[i] = quantity.DeepCopy()
+			definesValues = true
+		}
+	}
+	if override.IgnoreValues || definesValues {
+		merged.IgnoreValues = override.IgnoreValues
+	}
+	if override.LimitMode != "" {
+		merged.LimitMode = override.LimitMode
+	}
+	if override.OnExceed != "" {
+		merged.OnExceed = override.OnExceed
+	}
+	if override.RequestDerivation != "" {
+		merged.RequestDerivation = override.RequestDerivation
+	}
+	if override.LimitDerivation != "" {
+		merged.LimitDerivation = override.LimitDerivation
+	}
+	if override.OnGrowthExceed != "" {
+		merged.OnGrowthExceed = override.OnGrowthExceed
+	}
+	return &merged
+}
+
+// withOverrides returns a copy of the settings where the given overrides are
+// merged over the resource configurations
+func (s *Settings) withOverrides(overrides *ResourceOverrides) *Settings {
+	settings := *s
+	settings.Cpu, settings.Memory = nil, nil
+	settings.Resources = map[string]*ResourceConfiguration{}
+	for _, resourceName := range s.resourceNames() {
+		settings.Resources[resourceName] = mergeResourceConfiguration(s.resourceConfiguration(resourceName), overrides.resourceConfiguration(resourceName))
+	}
+	for _, resourceName := range overrides.resourceNames() {
+		if _, found := settings.Resources[resourceName]; !found {
+			settings.Resources[resourceName] = mergeResourceConfiguration(nil, overrides.resourceConfiguration(resourceName))
+		}
+	}
+	if overrides.Qos != nil {
+		settings.Qos = overrides.Qos
+	}
+	settings.Overrides = nil
+	return &settings
+}
+
+// matchesNamespace returns true when the namespace is equal to one of the
+// names, or matches one of the glob patterns, of the override
+func (o *NamespaceOverride) matchesNamespace(namespace string) bool {
+	for _, pattern := range o.Namespaces {
+		if matched, err := path.Match(pattern, namespace); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *NamespaceOverride) valid() error {
+	if len(o.Namespaces) == 0 {
+		return fmt.Errorf("at least one namespace must be defined")
+	}
+	for _, pattern := range o.Namespaces {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern '%s': %w", pattern, err)
+		}
+	}
+	return o.ResourceOverrides.valid()
+}
+
+// validOverrides checks the namespace overrides, and the settings resulting
+// from merging each one of them over the global settings
+func (s *Settings) validOverrides() error {
+	for i := range s.Overrides {
+		override := &s.Overrides[i]
+		err := override.valid()
+		if err == nil {
+			// The profiles merged over the overrides are checked later
+			settings := s.withOverrides(&override.ResourceOverrides)
+			settings.Profiles, settings.ImageProfiles, settings.ContainerProfiles = nil, nil, nil
+			err = settings.Valid()
+		}
+		if err != nil {
+			return errors.Join(fmt.Errorf("invalid overrides[%d] settings", i), err)
+		}
+	}
+	return nil
+}
+
+// forNamespace returns the settings used for the objects of the given
+// namespace. The first override matching the namespace is merged over the
+// global settings. The global settings are returned when no override matches
+func (s *Settings) forNamespace(namespace string) *Settings {
+	for i := range s.Overrides {
+		if s.Overrides[i].matchesNamespace(namespace) {
+			return s.withOverrides(&s.Overrides[i].ResourceOverrides)
+		}
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kubewarden/container-resources-policy/resource"
+)
+
+func TestMergeResourceConfiguration(t *testing.T) {
+	base := &ResourceConfiguration{
+		MaxLimit:       resource.MustParse("2"),
+		DefaultLimit:   resource.MustParse("1"),
+		DefaultRequest: resource.MustParse("500m"),
+	}
+	tests := []struct {
+		name     string
+		base     *ResourceConfiguration
+		override *ResourceConfiguration
+		expected *ResourceConfiguration
+	}{
+		{
+			"override values replace the base values",
+			base,
+			&ResourceConfiguration{MaxLimit: resource.MustParse("4"), MinRequest: resource.MustParse("100m")},
+			&ResourceConfiguration{
+				MaxLimit:       resource.MustParse("4"),
+				MinRequest:     resource.MustParse("100m"),
+				DefaultLimit:   resource.MustParse("1"),
+				DefaultRequest: resource.MustParse("500m"),
+			},
+		},
+		{
+			"override defines the action on exceed",
+			base,
+			&ResourceConfiguration{OnExceed: OnExceedClamp},
+			&ResourceConfiguration{
+				MaxLimit:       resource.MustParse("2"),
+				DefaultLimit:   resource.MustParse("1"),
+				DefaultRequest: resource.MustParse("500m"),
+				OnExceed:       OnExceedClamp,
+			},
+		},
+		{
+			"missing override",
+			base,
+			nil,
+			base,
+		},
+		{
+			"missing base",
+			nil,
+			&ResourceConfiguration{IgnoreValues: true},
+			&ResourceConfiguration{IgnoreValues: true},
+		},
+		{
+			"override enables ignoreValues",
+			base,
+			&ResourceConfiguration{IgnoreValues: true},
+			&ResourceConfiguration{
+				MaxLimit:       resource.MustParse("2"),
+				DefaultLimit:   resource.MustParse("1"),
+				DefaultRequest: resource.MustParse("500m"),
+				IgnoreValues:   true,
+			},
+		},
+		{
+			"override defining values disables ignoreValues",
+			&ResourceConfiguration{IgnoreValues: true},
+			&ResourceConfiguration{MaxLimit: resource.MustParse("4")},
+			&ResourceConfiguration{MaxLimit: resource.MustParse("4")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, err := json.Marshal(mergeResourceConfiguration(test.base, test.override))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected, err := json.Marshal(test.expected)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(string(expected), string(merged)); diff != "" {
+				t.Errorf("invalid merged configuration: %s", diff)
+			}
+		})
+	}
+	if base.MaxLimit.Cmp(resource.MustParse("2")) != 0 {
+		t.Errorf("the base configuration should not be changed")
+	}
+}
+
+func TestSettingsForNamespace(t *testing.T) {
+	rawSettings := []byte(`{
+		"cpu": {"maxLimit": "1", "defaultLimit": "1", "defaultRequest": "500m"},
+		"memory": {"maxLimit": "1Gi", "defaultLimit": "512Mi", "defaultRequest": "256Mi"},
+		"overrides": [
+			{"namespaces": ["batch"], "cpu": {"maxLimit": "8"}, "resources": {"ephemeral-storage": {"maxLimit": "10Gi"}}},
+			{"namespaces": ["batch-*", "web"], "memory": {"maxLimit": "4Gi", "defaultLimit": "2Gi"}},
+			{"namespaces": ["batch-large"], "memory": {"maxLimit": "64Gi"}}
+		]
+	}`)
+	settings := Settings{}
+	if err := json.Unmarshal(rawSettings, &settings); err != nil {
+		t.Fatalf("cannot parse settings: %v", err)
+	}
+	if err := settings.Valid(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		namespace         string
+		expectedCpuMax    string
+		expectedMemoryMax string
+		expectedResources []string
+	}{
+		{"default", "1", "1Gi", []string{"cpu", "memory"}},
+		{"batch", "8", "1Gi", []string{"cpu", "ephemeral-storage", "memory"}},
+		{"batch-small", "1", "4Gi", []string{"cpu", "memory"}},
+		{"batch-large", "1", "4Gi", []string{"cpu", "memory"}},
+		{"web", "1", "4Gi", []string{"cpu", "memory"}},
+	}
+	for _, test := range tests {
+		t.Run(test.namespace, func(t *testing.T) {
+			namespaceSettings := settings.forNamespace(test.namespace)
+			if diff := cmp.Diff(test.expectedResources, namespaceSettings.resourceNames()); diff != "" {
+				t.Errorf("invalid resources: %s", diff)
+			}
+			cpuMax := namespaceSettings.resourceConfiguration("cpu").MaxLimit
+			if expected := resource.MustParse(test.expectedCpuMax); cpuMax.Cmp(expected) != 0 {
+				t.Errorf("invalid cpu max limit. Expected %s, got %s", expected.String(), cpuMax.String())
+			}
+			memoryMax := namespaceSettings.resourceConfiguration("memory").MaxLimit
+			if expected := resource.MustParse(test.expectedMemoryMax); memoryMax.Cmp(expected) != 0 {
+				t.Errorf("invalid memory max limit. Expected %s, got %s", expected.String(), memoryMax.String())
+			}
+			if namespaceSettings.resourceConfiguration("cpu").DefaultRequest.Cmp(resource.MustParse("500m")) != 0 {
+				t.Errorf("the global default request should be kept")
+			}
+		})
+	}
+	if settings.Cpu.MaxLimit.Cmp(resource.MustParse("1")) != 0 {
+		t.Errorf("the global settings should not be changed")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+// resourceListSelector selects the limits or the requests of a container
+type resourceListSelector func(*corev1.ResourceRequirements) map[string]*api_resource.Quantity
+
+func selectLimits(resources *corev1.ResourceRequirements) map[string]*api_resource.Quantity {
+	return resources.Limits
+}
+
+// selectPodLevelRequests selects the pod level requests. Kubernetes defaults
+// the missing ones to the containers requests, not to the pod level limits
+func selectPodLevelRequests(resources *corev1.ResourceRequirements) map[string]*api_resource.Quantity {
+	return resources.Requests
+}
+
+// selectRequests selects the requests of a container. As done by Kubernetes,
+// the missing requests default to the limits, like the ones of the containers
+// which are not mutated by the policy
+func selectRequests(resources *corev1.ResourceRequirements) map[string]*api_resource.Quantity {
+	if len(resources.Limits) == 0 {
+		return resources.Requests
+	}
+	requests := map[string]*api_resource.Quantity{}
+	for resourceName, limit := range resources.Limits {
+		if missingResourceQuantity(resources.Requests, resourceName) {
+			requests[resourceName] = limit
+		}
+	}
+	for resourceName, request := range resources.Requests {
+		if !missingResourceQuantity(resources.Requests, resourceName) {
+			requests[resourceName] = request
+		}
+	}
+	return requests
+}
+
+// containerQuantity returns the quantity of the resource defined by the
+// container. Missing values are handled as zero, after the defaulting done by
+// the selector
+func containerQuantity(container *corev1.Container, resourceName string, selector resourceListSelector) (resource.Quantity, error) {
+	if container.Resources == nil {
+		return resource.Quantity{}, nil
+	}
+	resources := selector(container.Resources)
+	if missingResourceQuantity(resources, resourceName) {
+		return resource.Quantity{}, nil
+	}
+	quantity, err := resource.ParseQuantity(string(*resources[resourceName]))
+	if err != nil {
+		return resource.Quantity{}, invalidQuantityError(resourceName, string(*resources[resourceName]), "invalid %s quantity '%s' in container '%s'", resourceName, *resources[resourceName], containerName(container.Name))
+	}
+	return quantity, nil
+}
+
+// podResourceTotal returns the effective amount of the resource used by the
+// pod. It uses the same rules of Kubernetes: the containers and the sidecar
+// containers run together, so their values are summed. The regular init
+// containers run one at a time, together with the sidecar containers started
+// before them. The effective value is the max between these two phases.
+// Ephemeral containers are not considered.
+func podResourceTotal(pod *corev1.PodSpec, resourceName string, selector resourceListSelector) (resource.Quantity, error) {
+	total := resource.Quantity{}
+	for _, container := range pod.Containers {
+		quantity, err := containerQuantity(container, resourceName, selector)
+		if err != nil {
+			return resource.Quantity{}, err
+		}
+		total.Add(quantity)
+	}
+	sidecarsTotal := resource.Quantity{}
+	initContainersMax := resource.Quantity{}
+	for _, container := range pod.InitContainers {
+		quantity, err := containerQuantity(container, resourceName, selector)
+		if err != nil {
+			return resource.Quantity{}, err
+		}
+		if isSidecarContainer(container) {
+			total.Add(quantity)
+			sidecarsTotal.Add(quantity)
+			quantity = sidecarsTotal.DeepCopy()
+		} else {
+			quantity.Add(sidecarsTotal)
+		}
+		if quantity.Cmp(initContainersMax) > 0 {
+			initContainersMax = quantity
+		}
+	}
+	if initContainersMax.Cmp(total) > 0 {
+		return initContainersMax, nil
+	}
+	return total, nil
+}
+
+// podLevelQuantity returns the value of the resource defined by the pod level
+// resources (the resources field of the PodSpec). Returns false when it is
+// not defined
+func podLevelQuantity(podResources *corev1.ResourceRequirements, resourceName string, selector resourceListSelector) (resource.Quantity, bool, error) {
+	if podResources == nil {
+		return resource.Quantity{}, false, nil
+	}
+	resources := selector(podResources)
+	if missingResourceQuantity(resources, resourceName) {
+		return resource.Quantity{}, false, nil
+	}
+	quantity, err := resource.ParseQuantity(string(*resources[resourceName]))
+	if err != nil {
+		return resource.Quantity{}, false, invalidQuantityError(resourceName, string(*resources[resourceName]), "invalid pod level %s quantity '%s'", resourceName, *resources[resourceName])
+	}
+	return quantity, true, nil
+}
+
+// podEffectiveResource returns the amount of the resource accounted for the
+// pod, and the field defining it. The pod level resources take precedence
+// over the containers values. As done by Kubernetes, the pod overhead is
+// always added to the requests, and to the limits only when they are not zero.
+// The returned field is empty when the value is computed from the containers
+func podEffectiveResource(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements, resourceName, section string) (resource.Quantity, string, error) {
+	selector, podLevelSelector := selectRequests, selectPodLevelRequests
+	if section == "limits" {
+		selector, podLevelSelector = selectLimits, selectLimits
+	}
+	total, found, err := podLevelQuantity(podResources, resourceName, podLevelSelector)
+	if err != nil {
+		return resource.Quantity{}, "", err
+	}
+	field := ""
+	if found {
+		field = resourceField(section, resourceName)
+	} else {
+		total, err = podResourceTotal(pod, resourceName, selector)
+		if err != nil {
+			return resource.Quantity{}, "", err
+		}
+	}
+	if !missingResourceQuantity(pod.Overhead, resourceName) && (section == "requests" || !total.IsZero()) {
+		overhead, err := resource.ParseQuantity(string(*pod.Overhead[resourceName]))
+		if err != nil {
+			return resource.Quantity{}, "", invalidQuantityError(resourceName, string(*pod.Overhead[resourceName]), "invalid %s overhead '%s'", resourceName, *pod.Overhead[resourceName])
+		}
+		total.Add(overhead)
+	}
+	return total, field, nil
+}
+
+// podResourceNames returns the names of the resources defined by the pod
+// level resources
+func podResourceNames(podResources *corev1.ResourceRequirements) []string {
+	resourceNames := []string{}
+	if podResources == nil {
+		return resourceNames
+	}
+	for resourceName := range podResources.Limits {
+		resourceNames = append(resourceNames, resourceName)
+	}
+	for resourceName := range podResources.Requests {
+		if !slices.Contains(resourceNames, resourceName) {
+			resourceNames = append(resourceNames, resourceName)
+		}
+	}
+	slices.Sort(resourceNames)
+	return resourceNames
+}
+
+// validatePodResources validates the total resources of the pod against the
+// pod level bounds. The pod spec must be validated after the containers
+// mutation, so the default values are considered. The pod level resources
+// and the pod overhead are considered as well.
+func validatePodResources(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements, podSpecPath string, podConfig *PodConfiguration) []error {
+	violations := []error{}
+	if podConfig == nil {
+		return violations
+	}
+	resourceNames := []string{}
+	for resourceName := range podConfig.Resources {
+		resourceNames = append(resourceNames, resourceName)
+	}
+	slices.Sort(resourceNames)
+	for _, resourceName := range resourceNames {
+		resourceConfig := podConfig.Resources[resourceName]
+		if !resourceConfig.MaxLimit.IsZero() {
+			if err := validatePodResource(pod, podResources, podSpecPath, resourceName, "limits", resourceConfig.MaxLimit); err != nil {
+				violations = append(violations, err)
+			}
+		}
+		if !resourceConfig.MaxRequest.IsZero() {
+			if err := validatePodResource(pod, podResources, podSpecPath, resourceName, "requests", resourceConfig.MaxRequest); err != nil {
+				violations = append(violations, err)
+			}
+		}
+	}
+	return violations
+}
+
+func validatePodResource(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements, podSpecPath, resourceName, section string, maxValue resource.Quantity) error {
+	total, field, err := podEffectiveResource(pod, podResources, resourceName, section)
+	if err != nil {
+		return violationError{field: podSpecPath, err: err}
+	}
+	if total.Cmp(maxValue) <= 0 {
+		return nil
+	}
+	path := podSpecPath
+	if field != "" {
+		path = fmt.Sprintf("%s.%s", podSpecPath, field)
+	}
+	kind, rule := "limit", RulePodMaxLimit
+	if section == "requests" {
+		kind, rule = "request", RulePodMaxRequest
+	}
+	return violationError{
+		field: path,
+		err:   newRuleError(rule, resourceName, total.String(), maxValue.String(), "pod total %s %s '%s' exceeds the max allowed value '%s'", resourceName, kind, total.String(), maxValue.String()),
+	}
+}
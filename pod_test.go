@@ -0,0 +1,379 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	apimachinery_pkg_api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+func newContainerWithCpu(limit, request string) *corev1.Container {
+	container := &corev1.Container{
+		Resources: &corev1.ResourceRequirements{
+			Limits:   map[string]*apimachinery_pkg_api_resource.Quantity{},
+			Requests: map[string]*apimachinery_pkg_api_resource.Quantity{},
+		},
+	}
+	if limit != "" {
+		limitQuantity := apimachinery_pkg_api_resource.Quantity(limit)
+		container.Resources.Limits["cpu"] = &limitQuantity
+	}
+	if request != "" {
+		requestQuantity := apimachinery_pkg_api_resource.Quantity(request)
+		container.Resources.Requests["cpu"] = &requestQuantity
+	}
+	return container
+}
+
+func newSidecarWithCpu(limit, request string) *corev1.Container {
+	container := newContainerWithCpu(limit, request)
+	container.RestartPolicy = "Always"
+	return container
+}
+
+func TestPodResourceTotal(t *testing.T) {
+	tests := []struct {
+		name            string
+		pod             corev1.PodSpec
+		expectedLimit   string
+		expectedRequest string
+	}{
+		{
+			"containers are summed",
+			corev1.PodSpec{
+				Containers: []*corev1.Container{newContainerWithCpu("1", "500m"), newContainerWithCpu("2", "1")},
+			},
+			"3", "1500m",
+		},
+		{
+			"missing values are ignored",
+			corev1.PodSpec{
+				Containers: []*corev1.Container{newContainerWithCpu("", "500m"), {}},
+			},
+			"0", "500m",
+		},
+		{
+			"missing requests default to the limits",
+			corev1.PodSpec{
+				Containers: []*corev1.Container{newContainerWithCpu("1", ""), newContainerWithCpu("2", "1")},
+			},
+			"3", "2",
+		},
+		{
+			"init containers greater than the containers",
+			corev1.PodSpec{
+				Containers:     []*corev1.Container{newContainerWithCpu("1", "500m"), newContainerWithCpu("1", "500m")},
+				InitContainers: []*corev1.Container{newContainerWithCpu("3", "2"), newContainerWithCpu("1", "1")},
+			},
+			"3", "2",
+		},
+		{
+			"init containers less than the containers",
+			corev1.PodSpec{
+				Containers:     []*corev1.Container{newContainerWithCpu("2", "1"), newContainerWithCpu("2", "1")},
+				InitContainers: []*corev1.Container{newContainerWithCpu("3", "1")},
+			},
+			"4", "2",
+		},
+		{
+			"sidecar containers are summed with the containers",
+			corev1.PodSpec{
+				Containers:     []*corev1.Container{newContainerWithCpu("1", "1")},
+				InitContainers: []*corev1.Container{newSidecarWithCpu("1", "500m")},
+			},
+			"2", "1500m",
+		},
+		{
+			"init containers run with the sidecar containers started before them",
+			corev1.PodSpec{
+				Containers: []*corev1.Container{newContainerWithCpu("1", "1")},
+				InitContainers: []*corev1.Container{
+					newSidecarWithCpu("1", "1"),
+					newContainerWithCpu("3", "3"),
+					newSidecarWithCpu("1", "1"),
+				},
+			},
+			"4", "4",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limit, err := podResourceTotal(&test.pod, "cpu", selectLimits)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := resource.MustParse(test.expectedLimit); limit.Cmp(expected) != 0 {
+				t.Errorf("invalid limit. Expected %s, got %s", expected.String(), limit.String())
+			}
+			request, err := podResourceTotal(&test.pod, "cpu", selectRequests)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := resource.MustParse(test.expectedRequest); request.Cmp(expected) != 0 {
+				t.Errorf("invalid request. Expected %s, got %s", expected.String(), request.String())
+			}
+		})
+	}
+}
+
+func TestPodResourcesAreValidatedAfterMutation(t *testing.T) {
+	oneCore := resource.MustParse("1")
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{
+			newContainerWithCpu("1", "1"),
+			newContainerWithCpu("", ""),
+			newContainerWithCpu("", ""),
+		},
+	}
+	settings := Settings{
+		Cpu: &ResourceConfiguration{
+			DefaultLimit:   oneCore,
+			DefaultRequest: oneCore,
+			MaxLimit:       oneCore,
+		},
+		Pod: &PodConfiguration{
+			Resources: map[string]*PodResourceConfiguration{
+				"cpu": {
+					MaxLimit:   resource.MustParse("2"),
+					MaxRequest: resource.MustParse("3"),
+				},
+			},
+		},
+	}
+	_, _, err := validatePodSpec(podSpec, nil, "spec", &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	expectedErrorMsg := "spec: pod total cpu limit '3' exceeds the max allowed value '2'"
+	if err.Error() != expectedErrorMsg {
+		t.Errorf("invalid error message. Expected '%s'. Got '%s'", expectedErrorMsg, err.Error())
+	}
+
+	settings.Pod.Resources["cpu"].MaxLimit = resource.MustParse("3")
+	settings.Pod.Resources["cpu"].MaxRequest = resource.MustParse("2")
+	podSpec.Containers = []*corev1.Container{
+		newContainerWithCpu("1", "1"),
+		newContainerWithCpu("", ""),
+		newContainerWithCpu("", ""),
+	}
+	_, _, err = validatePodSpec(podSpec, nil, "spec", &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "pod total cpu request '3' exceeds the max allowed value '2'") {
+		t.Errorf("invalid error message. Got '%s'", err.Error())
+	}
+}
+
+func TestPodViolationsAreReportedWithContainerViolations(t *testing.T) {
+	oneCore := resource.MustParse("1")
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{
+			newContainerWithCpu("2", "1"),
+			newContainerWithCpu("", ""),
+		},
+	}
+	name := "app"
+	podSpec.Containers[0].Name = &name
+	settings := Settings{
+		Cpu: &ResourceConfiguration{
+			DefaultLimit:   oneCore,
+			DefaultRequest: oneCore,
+			MaxLimit:       oneCore,
+		},
+		Pod: &PodConfiguration{
+			Resources: map[string]*PodResourceConfiguration{
+				"cpu": {MaxLimit: resource.MustParse("2")},
+			},
+		},
+	}
+	_, _, err := validatePodSpec(podSpec, nil, "spec", &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, expected := range []string{
+		"spec.containers[0].resources.limits.cpu (container 'app'): cpu limit '2' exceeds the max allowed value '1'",
+		"spec: pod total cpu limit '3' exceeds the max allowed value '2'",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("invalid error message. Expected the string '%s', got '%s'", expected, err.Error())
+		}
+	}
+
+	// The invalid quantities are reported only once, by the container
+	podSpec.Containers = []*corev1.Container{newContainerWithCpu("abc", "")}
+	_, _, err = validatePodSpec(podSpec, nil, "spec", &settings)
+	if err == nil || len(flattenErrors(err)) != 1 {
+		t.Errorf("expected a single violation, got %v", err)
+	}
+}
+
+func TestPodOverheadAndPodLevelResources(t *testing.T) {
+	overhead := apimachinery_pkg_api_resource.Quantity("250m")
+	podLimit := apimachinery_pkg_api_resource.Quantity("4")
+	tests := []struct {
+		name            string
+		pod             corev1.PodSpec
+		podResources    *corev1.ResourceRequirements
+		expectedLimit   string
+		expectedRequest string
+		expectedField   string
+	}{
+		{
+			"overhead is added to the requests and the limits",
+			corev1.PodSpec{
+				Containers: []*corev1.Container{newContainerWithCpu("1", "500m")},
+				Overhead:   map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &overhead},
+			},
+			nil,
+			"1250m", "750m", "",
+		},
+		{
+			"overhead is not added to zero limits",
+			corev1.PodSpec{
+				Containers: []*corev1.Container{newContainerWithCpu("", "500m")},
+				Overhead:   map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &overhead},
+			},
+			nil,
+			"0", "750m", "",
+		},
+		{
+			"pod level resources take precedence over the containers",
+			corev1.PodSpec{
+				Containers: []*corev1.Container{newContainerWithCpu("1", "500m")},
+				Overhead:   map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &overhead},
+			},
+			&corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &podLimit},
+			},
+			"4250m", "750m", "resources.limits.cpu",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limit, field, err := podEffectiveResource(&test.pod, test.podResources, "cpu", "limits")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := resource.MustParse(test.expectedLimit); limit.Cmp(expected) != 0 {
+				t.Errorf("invalid limit. Expected %s, got %s", expected.String(), limit.String())
+			}
+			if field != test.expectedField {
+				t.Errorf("invalid field. Expected '%s', got '%s'", test.expectedField, field)
+			}
+			request, _, err := podEffectiveResource(&test.pod, test.podResources, "cpu", "requests")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := resource.MustParse(test.expectedRequest); request.Cmp(expected) != 0 {
+				t.Errorf("invalid request. Expected %s, got %s", expected.String(), request.String())
+			}
+		})
+	}
+}
+
+func TestPodLevelResourcesAreValidated(t *testing.T) {
+	podLimit := apimachinery_pkg_api_resource.Quantity("4")
+	podResources := &corev1.ResourceRequirements{
+		Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &podLimit},
+	}
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{newContainerWithCpu("1", "1")},
+	}
+	settings := Settings{
+		Pod: &PodConfiguration{
+			Resources: map[string]*PodResourceConfiguration{
+				"cpu": {MaxLimit: resource.MustParse("2")},
+			},
+		},
+	}
+	_, _, err := validatePodSpec(podSpec, podResources, "spec", &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	expectedErrorMsg := "spec.resources.limits.cpu: pod total cpu limit '4' exceeds the max allowed value '2'"
+	if err.Error() != expectedErrorMsg {
+		t.Errorf("invalid error message. Expected '%s'. Got '%s'", expectedErrorMsg, err.Error())
+	}
+}
+
+func TestContainerDefaultsAreSkippedWithPodLevelResources(t *testing.T) {
+	podLimit := apimachinery_pkg_api_resource.Quantity("2")
+	podResources := &corev1.ResourceRequirements{
+		Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &podLimit},
+	}
+	settings := Settings{
+		Cpu: &ResourceConfiguration{
+			DefaultLimit:   resource.MustParse("1"),
+			DefaultRequest: resource.MustParse("500m"),
+		},
+		Memory: &ResourceConfiguration{
+			DefaultLimit:   resource.MustParse("1Gi"),
+			DefaultRequest: resource.MustParse("512Mi"),
+		},
+		Pod: &PodConfiguration{SkipContainerDefaults: true},
+	}
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{newContainerWithCpu("", "")},
+	}
+	mutated, _, err := validatePodSpec(podSpec, podResources, "spec", &settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mutated {
+		t.Fatal("the memory defaults should be applied")
+	}
+	resources := podSpec.Containers[0].Resources
+	if _, found := resources.Limits["cpu"]; found {
+		t.Errorf("the cpu default limit should not be applied")
+	}
+	if _, found := resources.Requests["cpu"]; found {
+		t.Errorf("the cpu default request should not be applied")
+	}
+	if limit := resources.Limits["memory"]; limit == nil || *limit != "1Gi" {
+		t.Errorf("the memory default limit should be applied")
+	}
+	if settings.Cpu.skipDefaults {
+		t.Errorf("the original settings should not be changed")
+	}
+
+	podSpec.Containers = []*corev1.Container{newContainerWithCpu("", "")}
+	settings.Pod.SkipContainerDefaults = false
+	if _, _, err := validatePodSpec(podSpec, podResources, "spec", &settings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limit := podSpec.Containers[0].Resources.Limits["cpu"]; limit == nil || *limit != "1" {
+		t.Errorf("the cpu default limit should be applied")
+	}
+}
+
+func TestPodTotalsIncludeSkippedContainers(t *testing.T) {
+	busybox, nginx := "busybox", "nginx"
+	skipped := newContainerWithCpu("4", "")
+	skipped.Image = busybox
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{skipped, newContainerWithCpu("4", "1")},
+	}
+	podSpec.Containers[1].Image = nginx
+	settings := Settings{
+		Cpu: &ResourceConfiguration{
+			DefaultLimit:   resource.MustParse("1"),
+			DefaultRequest: resource.MustParse("1"),
+			MaxLimit:       resource.MustParse("4"),
+		},
+		IgnoreImages: []string{busybox},
+		Pod: &PodConfiguration{
+			Resources: map[string]*PodResourceConfiguration{
+				"cpu": {MaxRequest: resource.MustParse("2")},
+			},
+		},
+	}
+	// The request of the ignored container is not mutated. It defaults to
+	// its limit
+	_, _, err := validatePodSpec(podSpec, nil, "spec", &settings)
+	if err == nil || !strings.Contains(err.Error(), "pod total cpu request '5' exceeds the max allowed value '2'") {
+		t.Errorf("invalid error. Got %v", err)
+	}
+}
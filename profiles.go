@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+// Operators of the selector requirements
+const (
+	SelectorOperatorIn           = "In"
+	SelectorOperatorNotIn        = "NotIn"
+	SelectorOperatorExists       = "Exists"
+	SelectorOperatorDoesNotExist = "DoesNotExist"
+)
+
+// ResourceProfile defines resource configurations merged over the global ones
+// for the pods matching the selectors. The selectors are evaluated against
+// the labels and the annotations of the pod, or of the pod template
+type ResourceProfile struct {
+	Name string `json:"name"`
+	// Profiles with a higher priority take precedence. Profiles with the
+	// same priority are evaluated in order
+	Priority           int                   `json:"priority,omitempty"`
+	Selector           *metav1.LabelSelector `json:"selector,omitempty"`
+	AnnotationSelector *metav1.LabelSelector `json:"annotationSelector,omitempty"`
+	ResourceOverrides
+}
+
+func validSelector(selector *metav1.LabelSelector) error {
+	for i, requirement := range selector.MatchExpressions {
+		if requirement == nil || requirement.Key == nil || *requirement.Key == "" {
+			return fmt.Errorf("matchExpressions[%d]: key must be defined", i)
+		}
+		if requirement.Operator == nil {
+			return fmt.Errorf("matchExpressions[%d]: operator must be defined", i)
+		}
+		switch *requirement.Operator {
+		case SelectorOperatorIn, SelectorOperatorNotIn:
+			if len(requirement.Values) == 0 {
+				return fmt.Errorf("matchExpressions[%d]: values must be defined for the %s operator", i, *requirement.Operator)
+			}
+		case SelectorOperatorExists, SelectorOperatorDoesNotExist:
+			if len(requirement.Values) > 0 {
+				return fmt.Errorf("matchExpressions[%d]: values cannot be defined for the %s operator", i, *requirement.Operator)
+			}
+		default:
+			return fmt.Errorf("matchExpressions[%d]: invalid operator '%s'. Valid values are: %s, %s, %s, %s", i, *requirement.Operator,
+				SelectorOperatorIn, SelectorOperatorNotIn, SelectorOperatorExists, SelectorOperatorDoesNotExist)
+		}
+	}
+	return nil
+}
+
+// selectorMatches returns true when the values satisfy all the requirements
+// of the selector. A nil selector matches everything
+func selectorMatches(selector *metav1.LabelSelector, values map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	for key, expected := range selector.MatchLabels {
+		if value, found := values[key]; !found || value != expected {
+			return false
+		}
+	}
+	for _, requirement := range selector.MatchExpressions {
+		value, found := values[*requirement.Key]
+		switch *requirement.Operator {
+		case SelectorOperatorIn:
+			if !found || !slices.Contains(requirement.Values, value) {
+				return false
+			}
+		case SelectorOperatorNotIn:
+			if found && slices.Contains(requirement.Values, value) {
+				return false
+			}
+		case SelectorOperatorExists:
+			if !found {
+				return false
+			}
+		case SelectorOperatorDoesNotExist:
+			if found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *ResourceProfile) matches(labels, annotations map[string]string) bool {
+	return selectorMatches(p.Selector, labels) && selectorMatches(p.AnnotationSelector, annotations)
+}
+
+func (p *ResourceProfile) valid() error {
+	if p.Name == "" {
+		return fmt.Errorf("name must be defined")
+	}
+	if p.Selector == nil && p.AnnotationSelector == nil {
+		return fmt.Errorf("at least one of selector or annotationSelector must be defined")
+	}
+	if p.Selector != nil {
+		if err := validSelector(p.Selector); err != nil {
+			return errors.Join(fmt.Errorf("invalid selector"), err)
+		}
+	}
+	if p.AnnotationSelector != nil {
+		if err := validSelector(p.AnnotationSelector); err != nil {
+			return errors.Join(fmt.Errorf("invalid annotationSelector"), err)
+		}
+	}
+	return p.ResourceOverrides.valid()
+}
+
+// validProfiles checks the profiles, and the settings resulting from merging
+// each one of them over the global settings and over each namespace override
+func (s *Settings) validProfiles() error {
+	base := *s
+	base.Overrides, base.Profiles, base.ImageProfiles, base.ContainerProfiles = nil, nil, nil, nil
+	baseSettings := []*Settings{&base}
+	for i := range s.Overrides {
+		baseSettings = append(baseSettings, base.withOverrides(&s.Overrides[i].ResourceOverrides))
+	}
+	names := []string{}
+	for i := range s.Profiles {
+		profile := &s.Profiles[i]
+		err := profile.valid()
+		if err == nil && slices.Contains(names, profile.Name) {
+			err = fmt.Errorf("duplicated profile name '%s'", profile.Name)
+		}
+		for _, settings := range baseSettings {
+			if err != nil {
+				break
+			}
+			err = settings.withOverrides(&profile.ResourceOverrides).Valid()
+		}
+		if err != nil {
+			return errors.Join(fmt.Errorf("invalid profiles[%d] settings", i), err)
+		}
+		names = append(names, profile.Name)
+	}
+	return nil
+}
+
+// matchingProfile returns the profile with the highest priority matching the
+// given labels and annotations. Profiles with the same priority are evaluated
+// in order. Returns nil when no profile matches
+func (s *Settings) matchingProfile(labels, annotations map[string]string) *ResourceProfile {
+	var matching *ResourceProfile
+	for i := range s.Profiles {
+		profile := &s.Profiles[i]
+		if (matching == nil || profile.Priority > matching.Priority) && profile.matches(labels, annotations) {
+			matching = profile
+		}
+	}
+	return matching
+}
+
+// forPod returns the settings used for a pod with the given labels and
+// annotations. The matching profile is merged over the settings
+func (s *Settings) forPod(labels, annotations map[string]string) *Settings {
+	profile := s.matchingProfile(labels, annotations)
+	if profile == nil {
+		return s
+	}
+	settings := s.withOverrides(&profile.ResourceOverrides)
+	settings.Profiles = nil
+	return settings
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kubewarden/container-resources-policy/resource"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"workload-class": "batch-large", "team": "data"}
+	tests := []struct {
+		name     string
+		selector string
+		expected bool
+	}{
+		{"match labels", `{"matchLabels": {"workload-class": "batch-large"}}`, true},
+		{"match labels with different value", `{"matchLabels": {"workload-class": "batch-small"}}`, false},
+		{"match labels with missing label", `{"matchLabels": {"tier": "backend"}}`, false},
+		{"In operator", `{"matchExpressions": [{"key": "team", "operator": "In", "values": ["data", "ml"]}]}`, true},
+		{"In operator with missing label", `{"matchExpressions": [{"key": "tier", "operator": "In", "values": ["backend"]}]}`, false},
+		{"NotIn operator", `{"matchExpressions": [{"key": "team", "operator": "NotIn", "values": ["data"]}]}`, false},
+		{"NotIn operator with missing label", `{"matchExpressions": [{"key": "tier", "operator": "NotIn", "values": ["backend"]}]}`, true},
+		{"Exists operator", `{"matchExpressions": [{"key": "team", "operator": "Exists"}]}`, true},
+		{"DoesNotExist operator", `{"matchExpressions": [{"key": "team", "operator": "DoesNotExist"}]}`, false},
+		{"all the requirements must match", `{"matchLabels": {"team": "data"}, "matchExpressions": [{"key": "tier", "operator": "Exists"}]}`, false},
+		{"empty selector", `{}`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile := ResourceProfile{}
+			if err := json.Unmarshal([]byte(`{"name": "test", "selector": `+test.selector+`}`), &profile); err != nil {
+				t.Fatalf("cannot parse profile: %v", err)
+			}
+			if err := profile.valid(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if matches := profile.matches(labels, map[string]string{}); matches != test.expected {
+				t.Errorf("invalid match result. Expected %t, got %t", test.expected, matches)
+			}
+		})
+	}
+}
+
+func TestSettingsForPod(t *testing.T) {
+	rawSettings := []byte(`{
+		"cpu": {"maxLimit": "1", "defaultLimit": "1", "defaultRequest": "500m"},
+		"overrides": [{"namespaces": ["batch"], "cpu": {"defaultRequest": "250m"}}],
+		"profiles": [
+			{"name": "batch", "selector": {"matchExpressions": [{"key": "workload-class", "operator": "In", "values": ["batch-small", "batch-large"]}]}, "cpu": {"maxLimit": "2"}},
+			{"name": "batch-large", "priority": 10, "selector": {"matchLabels": {"workload-class": "batch-large"}}, "cpu": {"maxLimit": "8", "defaultLimit": "4"}},
+			{"name": "other-batch", "selector": {"matchLabels": {"workload-class": "batch-small"}}, "cpu": {"maxLimit": "3"}},
+			{"name": "annotated", "annotationSelector": {"matchLabels": {"example.com/size": "large"}}, "cpu": {"maxLimit": "4"}}
+		]
+	}`)
+	settings := Settings{}
+	if err := json.Unmarshal(rawSettings, &settings); err != nil {
+		t.Fatalf("cannot parse settings: %v", err)
+	}
+	if err := settings.Valid(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name                   string
+		namespace              string
+		labels                 map[string]string
+		annotations            map[string]string
+		expectedMaxLimit       string
+		expectedDefaultLimit   string
+		expectedDefaultRequest string
+	}{
+		{"no matching profile", "default", map[string]string{"app": "nginx"}, map[string]string{}, "1", "1", "500m"},
+		{"matching profile", "default", map[string]string{"workload-class": "batch-small"}, map[string]string{}, "2", "1", "500m"},
+		{"profile with higher priority", "default", map[string]string{"workload-class": "batch-large"}, map[string]string{}, "8", "4", "500m"},
+		{"annotation selector", "default", map[string]string{}, map[string]string{"example.com/size": "large"}, "4", "1", "500m"},
+		{"profile merged over the namespace override", "batch", map[string]string{"workload-class": "batch-large"}, map[string]string{}, "8", "4", "250m"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			podSettings := settings.forNamespace(test.namespace).forPod(test.labels, test.annotations)
+			cpu := podSettings.resourceConfiguration("cpu")
+			actual := []string{cpu.MaxLimit.String(), cpu.DefaultLimit.String(), cpu.DefaultRequest.String()}
+			expected := []string{}
+			for _, value := range []string{test.expectedMaxLimit, test.expectedDefaultLimit, test.expectedDefaultRequest} {
+				quantity := resource.MustParse(value)
+				expected = append(expected, quantity.String())
+			}
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Errorf("invalid cpu settings: %s", diff)
+			}
+		})
+	}
+}
+
+func TestPodMetadata(t *testing.T) {
+	tests := []struct {
+		name                string
+		rawObject           string
+		podSpecPath         string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			"pod",
+			`{"metadata": {"labels": {"app": "nginx"}, "annotations": {"note": "value"}}, "spec": {}}`,
+			"spec",
+			map[string]string{"app": "nginx"},
+			map[string]string{"note": "value"},
+		},
+		{
+			"deployment template",
+			`{"metadata": {"labels": {"app": "deployment"}}, "spec": {"template": {"metadata": {"labels": {"app": "nginx"}}, "spec": {}}}}`,
+			"spec.template.spec",
+			map[string]string{"app": "nginx"},
+			map[string]string{},
+		},
+		{
+			"cronjob template",
+			`{"spec": {"jobTemplate": {"spec": {"template": {"metadata": {"labels": {"app": "job"}}, "spec": {}}}}}}`,
+			"spec.jobTemplate.spec.template.spec",
+			map[string]string{"app": "job"},
+			map[string]string{},
+		},
+		{
+			"missing metadata",
+			`{"spec": {"template": {"spec": {}}}}`,
+			"spec.template.spec",
+			map[string]string{},
+			map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object, err := newObject([]byte(test.rawObject))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			labels, annotations := object.podMetadata(test.podSpecPath)
+			if diff := cmp.Diff(test.expectedLabels, labels); diff != "" {
+				t.Errorf("invalid labels: %s", diff)
+			}
+			if diff := cmp.Diff(test.expectedAnnotations, annotations); diff != "" {
+				t.Errorf("invalid annotations: %s", diff)
+			}
+		})
+	}
+}
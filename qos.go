@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+// QoS classes assigned by Kubernetes to the pods
+const (
+	QosGuaranteed = "Guaranteed"
+	QosBurstable  = "Burstable"
+	QosBestEffort = "BestEffort"
+)
+
+// Actions taken when the QoS class of a pod is not allowed
+const (
+	// Reject the pod
+	QosActionReject = "reject"
+	// Set the requests equal to the limits, so the pod becomes Guaranteed
+	QosActionMutate = "mutate"
+)
+
+// qosResourceNames are the resources used by Kubernetes to compute the QoS
+// class of the pods
+var qosResourceNames = []string{"cpu", "memory"}
+
+// QosConfiguration restricts the QoS classes of the pods
+type QosConfiguration struct {
+	AllowedClasses []string `json:"allowedClasses"`
+	// What to do with the pods whose class is not allowed. Defaults to reject
+	Action string `json:"action,omitempty"`
+}
+
+func (c *QosConfiguration) valid() error {
+	if len(c.AllowedClasses) == 0 {
+		return fmt.Errorf("at least one QoS class must be allowed")
+	}
+	for _, class := range c.AllowedClasses {
+		switch class {
+		case QosGuaranteed, QosBurstable, QosBestEffort:
+		default:
+			return fmt.Errorf("invalid QoS class '%s'. Valid values are: %s, %s, %s", class, QosGuaranteed, QosBurstable, QosBestEffort)
+		}
+	}
+	switch c.Action {
+	case "", QosActionReject:
+	case QosActionMutate:
+		if !slices.Contains(c.AllowedClasses, QosGuaranteed) {
+			return fmt.Errorf("the %s action requires the %s class to be allowed", c.Action, QosGuaranteed)
+		}
+	default:
+		return fmt.Errorf("invalid action '%s'. Valid values are: %s, %s", c.Action, QosActionReject, QosActionMutate)
+	}
+	return nil
+}
+
+// qosQuantity returns the quantity of the resource, or zero when it is not
+// defined
+func qosQuantity(resources map[string]*api_resource.Quantity, resourceName string) (resource.Quantity, error) {
+	if missingResourceQuantity(resources, resourceName) {
+		return resource.Quantity{}, nil
+	}
+	quantity, err := resource.ParseQuantity(string(*resources[resourceName]))
+	if err != nil {
+		return resource.Quantity{}, invalidQuantityError(resourceName, string(*resources[resourceName]), "invalid %s quantity '%s'", resourceName, *resources[resourceName])
+	}
+	return quantity, nil
+}
+
+// definesQosResources returns true when the pod level resources define any of
+// the resources used to compute the QoS class
+func definesQosResources(podResources *corev1.ResourceRequirements) bool {
+	if podResources == nil {
+		return false
+	}
+	for _, resourceName := range qosResourceNames {
+		if !missingResourceQuantity(podResources.Limits, resourceName) || !missingResourceQuantity(podResources.Requests, resourceName) {
+			return true
+		}
+	}
+	return false
+}
+
+// qosClass returns the QoS class of the pod, computed with the same rules of
+// Kubernetes: only the cpu and memory of the containers and of the init
+// containers are considered. When the pod level resources define them, they
+// are used instead of the container values. As done by Kubernetes, missing
+// requests are equal to the limits.
+func qosClass(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements) (string, error) {
+	resourcesList := []*corev1.ResourceRequirements{}
+	if definesQosResources(podResources) {
+		resourcesList = append(resourcesList, podResources)
+	} else {
+		for _, container := range slices.Concat(pod.Containers, pod.InitContainers) {
+			resourcesList = append(resourcesList, container.Resources)
+		}
+	}
+	requests, limits := map[string]resource.Quantity{}, map[string]resource.Quantity{}
+	guaranteed := true
+	for _, resources := range resourcesList {
+		var resourceLimits, resourceRequests map[string]*api_resource.Quantity
+		if resources != nil {
+			resourceLimits, resourceRequests = resources.Limits, resources.Requests
+		}
+		limitsFound := 0
+		for _, resourceName := range qosResourceNames {
+			limit, err := qosQuantity(resourceLimits, resourceName)
+			if err != nil {
+				return "", err
+			}
+			request, err := qosQuantity(resourceRequests, resourceName)
+			if err != nil {
+				return "", err
+			}
+			if missingResourceQuantity(resourceRequests, resourceName) {
+				request = limit
+			}
+			if request.Sign() > 0 {
+				total := requests[resourceName]
+				total.Add(request)
+				requests[resourceName] = total
+			}
+			if limit.Sign() > 0 {
+				limitsFound++
+				total := limits[resourceName]
+				total.Add(limit)
+				limits[resourceName] = total
+			}
+		}
+		if limitsFound < len(qosResourceNames) {
+			guaranteed = false
+		}
+	}
+	if len(requests) == 0 && len(limits) == 0 {
+		return QosBestEffort, nil
+	}
+	for resourceName, request := range requests {
+		if limit, found := limits[resourceName]; !found || limit.Cmp(request) != 0 {
+			guaranteed = false
+		}
+	}
+	if guaranteed && len(requests) == len(limits) {
+		return QosGuaranteed, nil
+	}
+	return QosBurstable, nil
+}
+
+// guaranteeContainer sets the cpu and memory requests of the container equal
+// to its limits. Returns true when it mutates the container
+func guaranteeContainer(container *corev1.Container) bool {
+	if container.Resources == nil {
+		return false
+	}
+	mutated := false
+	for _, resourceName := range qosResourceNames {
+		if missingResourceQuantity(container.Resources.Limits, resourceName) {
+			continue
+		}
+		limit, err := qosQuantity(container.Resources.Limits, resourceName)
+		if err != nil {
+			continue
+		}
+		if request, err := qosQuantity(container.Resources.Requests, resourceName); err == nil &&
+			!missingResourceQuantity(container.Resources.Requests, resourceName) && request.Cmp(limit) == 0 {
+			continue
+		}
+		if container.Resources.Requests == nil {
+			container.Resources.Requests = make(map[string]*api_resource.Quantity)
+		}
+		newRequest := *container.Resources.Limits[resourceName]
+		container.Resources.Requests[resourceName] = &newRequest
+		mutated = true
+	}
+	return mutated
+}
+
+// guaranteeContainers sets the requests equal to the limits in all the
+// containers the policy can mutate. The new requests are validated against
+// the container settings, and their growth is checked on UPDATE requests.
+// Returns true when it mutates any container, with the warnings and the
+// violations found.
+func guaranteeContainers(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements, podSpecPath string, settings *Settings) (bool, []error, []error) {
+	mutated := false
+	violations, warnings := []error{}, []error{}
+	guarantee := func(container *corev1.Container, containerPath, action, kind string) {
+		if isContainerSkipped(container, action, settings) || action != ContainerActionDefault || settings.DisableMutation || settings.isContainerWarnedOnly(container) {
+			return
+		}
+		containerCopy := *container
+		containerCopy.Resources = &corev1.ResourceRequirements{
+			Limits:   maps.Clone(container.Resources.Limits),
+			Requests: maps.Clone(container.Resources.Requests),
+		}
+		if !guaranteeContainer(&containerCopy) {
+			return
+		}
+		// The requests must be within the bounds, and the limit request ratios
+		// must allow them to be equal to the limits
+		adjustedSettings := containerSettings(container, podResources, settings)
+		errs := validateAdjustedRequests(&containerCopy, adjustedSettings)
+		for _, resourceName := range adjustedSettings.resourceNames() {
+			if adjustedSettings.shouldIgnoreValues(resourceName) {
+				continue
+			}
+			if err := validateLimitRequestRatio(&containerCopy, resourceName, adjustedSettings.resourceConfiguration(resourceName)); err != nil {
+				errs = append(errs, limitError(resourceName, err))
+			}
+		}
+		// The raised requests cannot grow more than allowed
+		growthWarnings, growthErr := validateContainerGrowth(&containerCopy, adjustedSettings)
+		if growthErr != nil {
+			errs = append(errs, growthErr)
+		}
+		if len(errs) > 0 {
+			violations = append(violations, containerViolations(errors.Join(errs...), containerPath, kind, container.Name)...)
+			return
+		}
+		warnings = append(warnings, containerViolations(errors.Join(growthWarnings...), containerPath, kind, container.Name)...)
+		container.Resources = containerCopy.Resources
+		mutated = true
+	}
+	for i, container := range pod.Containers {
+		if container.Resources != nil {
+			guarantee(container, fmt.Sprintf("%s.containers[%d]", podSpecPath, i), ContainerActionDefault, "container")
+		}
+	}
+	for i, container := range pod.InitContainers {
+		if container.Resources != nil {
+			action, kind := initContainerAction(container, settings)
+			guarantee(container, fmt.Sprintf("%s.initContainers[%d]", podSpecPath, i), action, kind)
+		}
+	}
+	return mutated, warnings, violations
+}
+
+// validatePodQos checks the QoS class of the pod, computed after the mutation
+// of the containers. When configured, the requests are set equal to the
+// limits to make the pod Guaranteed. The pod level resources are never
+// mutated.
+// Returns true when it mutates the pod, with the warnings and the violations
+// found.
+func validatePodQos(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements, podSpecPath string, settings *Settings) (bool, []error, []error) {
+	if settings.Qos == nil {
+		return false, nil, nil
+	}
+	allowedClasses := settings.Qos.AllowedClasses
+	class, err := qosClass(pod, podResources)
+	if err != nil {
+		return false, nil, []error{violationError{field: podSpecPath, err: err}}
+	}
+	if slices.Contains(allowedClasses, class) {
+		return false, nil, nil
+	}
+	mutated := false
+	violations, warnings := []error{}, []error{}
+	if settings.Qos.Action == QosActionMutate && !definesQosResources(podResources) {
+		mutated, warnings, violations = guaranteeContainers(pod, podResources, podSpecPath, settings)
+		if class, err = qosClass(pod, podResources); err != nil {
+			return mutated, warnings, append(violations, violationError{field: podSpecPath, err: err})
+		}
+	}
+	if !slices.Contains(allowedClasses, class) {
+		violations = append(violations, violationError{
+			field: podSpecPath,
+			err:   newRuleError(RuleQosClass, "", class, strings.Join(allowedClasses, ","), "pod QoS class %s is not allowed. Allowed classes: %s", class, strings.Join(allowedClasses, ", ")),
+		})
+	}
+	return mutated, warnings, violations
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	apimachinery_pkg_api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+// newContainerWithCpuAndMemory returns a container with the given cpu and
+// memory values, in the limit/request format. Empty values are not defined
+func newContainerWithCpuAndMemory(cpu, memory string) *corev1.Container {
+	container := newContainerWithCpu("", "")
+	for resourceName, values := range map[string]string{"cpu": cpu, "memory": memory} {
+		limit, request, _ := strings.Cut(values, "/")
+		if limit != "" {
+			limitQuantity := apimachinery_pkg_api_resource.Quantity(limit)
+			container.Resources.Limits[resourceName] = &limitQuantity
+		}
+		if request != "" {
+			requestQuantity := apimachinery_pkg_api_resource.Quantity(request)
+			container.Resources.Requests[resourceName] = &requestQuantity
+		}
+	}
+	return container
+}
+
+func TestQosClass(t *testing.T) {
+	tests := []struct {
+		name          string
+		pod           corev1.PodSpec
+		podResources  *corev1.ResourceRequirements
+		expectedClass string
+	}{
+		{"no resources", corev1.PodSpec{Containers: []*corev1.Container{{}, newContainerWithCpuAndMemory("", "")}}, nil, QosBestEffort},
+		{"requests equal to limits", corev1.PodSpec{Containers: []*corev1.Container{newContainerWithCpuAndMemory("1/1", "1Gi/1Gi")}}, nil, QosGuaranteed},
+		{"missing requests are equal to the limits", corev1.PodSpec{Containers: []*corev1.Container{newContainerWithCpuAndMemory("1/", "1Gi/")}}, nil, QosGuaranteed},
+		{"equal quantities in different formats", corev1.PodSpec{Containers: []*corev1.Container{newContainerWithCpuAndMemory("1/1000m", "1Gi/1024Mi")}}, nil, QosGuaranteed},
+		{"request lower than the limit", corev1.PodSpec{Containers: []*corev1.Container{newContainerWithCpuAndMemory("1/500m", "1Gi/1Gi")}}, nil, QosBurstable},
+		{"missing memory limit", corev1.PodSpec{Containers: []*corev1.Container{newContainerWithCpuAndMemory("1/1", "/1Gi")}}, nil, QosBurstable},
+		{"init container without limits", corev1.PodSpec{
+			Containers:     []*corev1.Container{newContainerWithCpuAndMemory("1/1", "1Gi/1Gi")},
+			InitContainers: []*corev1.Container{newContainerWithCpuAndMemory("", "")},
+		}, nil, QosBurstable},
+		{"pod level resources", corev1.PodSpec{Containers: []*corev1.Container{newContainerWithCpuAndMemory("", "")}}, newContainerWithCpuAndMemory("2/2", "2Gi/2Gi").Resources, QosGuaranteed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			class, err := qosClass(&test.pod, test.podResources)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if class != test.expectedClass {
+				t.Errorf("expected class %s, got %s", test.expectedClass, class)
+			}
+		})
+	}
+}
+
+func TestPodQos(t *testing.T) {
+	tests := []struct {
+		name             string
+		containers       []*corev1.Container
+		qos              QosConfiguration
+		ignoreImages     []string
+		expectedMutation bool
+		expectedRequest  string
+		expectedErrorMsg string
+	}{
+		{"allowed class", []*corev1.Container{newContainerWithCpuAndMemory("1/500m", "1Gi/1Gi")}, QosConfiguration{AllowedClasses: []string{QosBurstable}}, nil, false, "500m", ""},
+		{"rejected class", []*corev1.Container{newContainerWithCpuAndMemory("1/500m", "1Gi/1Gi")}, QosConfiguration{AllowedClasses: []string{QosGuaranteed}}, nil, false, "500m", "spec: pod QoS class Burstable is not allowed. Allowed classes: Guaranteed"},
+		{"requests set equal to the limits", []*corev1.Container{newContainerWithCpuAndMemory("1/500m", "1Gi/1Gi")}, QosConfiguration{AllowedClasses: []string{QosGuaranteed}, Action: QosActionMutate}, nil, true, "1", ""},
+		{"defaults applied before the QoS mutation", []*corev1.Container{newContainerWithCpuAndMemory("", "1Gi/1Gi")}, QosConfiguration{AllowedClasses: []string{QosGuaranteed}, Action: QosActionMutate}, nil, true, "1", ""},
+		{"ignored containers are not mutated", []*corev1.Container{newContainerWithCpuAndMemory("1/500m", "1Gi/1Gi")}, QosConfiguration{AllowedClasses: []string{QosGuaranteed}, Action: QosActionMutate}, []string{"*"}, false, "500m", "spec: pod QoS class Burstable is not allowed"},
+		{"containers without memory limits", []*corev1.Container{newContainerWithCpuAndMemory("1/500m", "")}, QosConfiguration{AllowedClasses: []string{QosGuaranteed}, Action: QosActionMutate}, nil, true, "1", "spec: pod QoS class Burstable is not allowed"},
+		{"requests exceeding the max request", []*corev1.Container{newContainerWithCpuAndMemory("2/500m", "1Gi/1Gi")}, QosConfiguration{AllowedClasses: []string{QosGuaranteed}, Action: QosActionMutate}, nil, false, "500m", "spec.containers[0].resources.requests.cpu (container ''): There is an issue after resource requests mutation: cpu request '2' exceeds the max allowed request value '1500m'"},
+		{"best effort forbidden", []*corev1.Container{newContainerWithCpuAndMemory("", "")}, QosConfiguration{AllowedClasses: []string{QosGuaranteed, QosBurstable}}, []string{"*"}, false, "", "spec: pod QoS class BestEffort is not allowed. Allowed classes: Guaranteed, Burstable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			podSpec := &corev1.PodSpec{Containers: test.containers}
+			settings := Settings{
+				Cpu: &ResourceConfiguration{
+					MaxLimit:       resource.MustParse("2"),
+					MaxRequest:     resource.MustParse("1500m"),
+					DefaultLimit:   resource.MustParse("1"),
+					DefaultRequest: resource.MustParse("500m"),
+				},
+				IgnoreImages: test.ignoreImages,
+				Qos:          &test.qos,
+			}
+			mutated, _, err := validatePodSpec(podSpec, nil, "spec", &settings)
+			if err != nil && len(test.expectedErrorMsg) == 0 {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if len(test.expectedErrorMsg) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectedErrorMsg)) {
+				t.Errorf("invalid error message. Expected the string '%s' in the error. Got '%v'", test.expectedErrorMsg, err)
+			}
+			if mutated != test.expectedMutation {
+				t.Errorf("expected mutation to be %t, got %t", test.expectedMutation, mutated)
+			}
+			request := ""
+			if quantity := podSpec.Containers[0].Resources.Requests["cpu"]; quantity != nil {
+				request = string(*quantity)
+			}
+			if request != test.expectedRequest {
+				t.Errorf("expected cpu request '%s', got '%s'", test.expectedRequest, request)
+			}
+		})
+	}
+}
+
+func TestPodQosMutationGrowth(t *testing.T) {
+	tests := []struct {
+		name             string
+		onGrowthExceed   string
+		expectedMutation bool
+		expectedRequest  string
+		expectedErrorMsg string
+		expectedWarnings int
+	}{
+		{"growth rejected", OnGrowthExceedReject, false, "250m", "spec.containers[0].resources.requests.cpu (container ''): cpu request '1' grows too much from the previous value '250m'. The max allowed value is '500m'", 0},
+		{"growth warned", OnGrowthExceedWarn, true, "1", "", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldPod := &corev1.PodSpec{Containers: []*corev1.Container{newContainerWithCpuAndMemory("1/250m", "1Gi/1Gi")}}
+			podSpec := &corev1.PodSpec{Containers: []*corev1.Container{newContainerWithCpuAndMemory("1/250m", "1Gi/1Gi")}}
+			settings := (&Settings{
+				Cpu: &ResourceConfiguration{
+					MaxLimit:        resource.MustParse("2"),
+					DefaultLimit:    resource.MustParse("1"),
+					DefaultRequest:  resource.MustParse("500m"),
+					MaxGrowthFactor: resource.MustParse("2"),
+					OnGrowthExceed:  test.onGrowthExceed,
+				},
+				Qos: &QosConfiguration{AllowedClasses: []string{QosGuaranteed}, Action: QosActionMutate},
+			}).withOldPod(oldPod)
+			mutated, warnings, err := validatePodSpec(podSpec, nil, "spec", settings)
+			if err != nil && len(test.expectedErrorMsg) == 0 {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if len(test.expectedErrorMsg) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectedErrorMsg)) {
+				t.Errorf("invalid error message. Expected the string '%s' in the error. Got '%v'", test.expectedErrorMsg, err)
+			}
+			if mutated != test.expectedMutation {
+				t.Errorf("expected mutation to be %t, got %t", test.expectedMutation, mutated)
+			}
+			if len(warnings) != test.expectedWarnings {
+				t.Errorf("expected %d warnings, got %v", test.expectedWarnings, warnings)
+			}
+			if request := string(*podSpec.Containers[0].Resources.Requests["cpu"]); request != test.expectedRequest {
+				t.Errorf("expected cpu request '%s', got '%s'", test.expectedRequest, request)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// Audit annotation recording the violations accepted by the audit mode
+const violationsAuditAnnotation = "violations"
+
+// ValidationResponse extends the response of the policy SDK with the fields
+// of the Kubewarden protocol not available in the SDK: the warnings shown to
+// the user and the annotations added to the audit event of the request
+type ValidationResponse struct {
+	kubewarden_protocol.ValidationResponse
+	Warnings         []string          `json:"warnings,omitempty"`
+	AuditAnnotations map[string]string `json:"audit_annotations,omitempty"`
+}
+
+// acceptRequest accepts the request, returning the given warnings to the user
+// and adding the given annotations to its audit event
+func acceptRequest(warnings []string, auditAnnotations map[string]string) ([]byte, error) {
+	return mutateRequest(nil, warnings, auditAnnotations)
+}
+
+// mutateRequest accepts the request, replacing the object with the given one
+// when it is not nil. The given warnings are returned to the user and the
+// given annotations are added to the audit event
+func mutateRequest(mutatedObject interface{}, warnings []string, auditAnnotations map[string]string) ([]byte, error) {
+	response := ValidationResponse{
+		ValidationResponse: kubewarden_protocol.ValidationResponse{
+			Accepted:      true,
+			MutatedObject: mutatedObject,
+		},
+		Warnings:         warnings,
+		AuditAnnotations: auditAnnotations,
+	}
+	return json.Marshal(response)
+}
+
+// rejectRequest rejects the request with the given message and code, adding
+// the given annotations to its audit event
+func rejectRequest(message string, code uint16, auditAnnotations map[string]string) ([]byte, error) {
+	response := ValidationResponse{
+		ValidationResponse: kubewarden_protocol.ValidationResponse{
+			Accepted: false,
+			Message:  &message,
+			Code:     &code,
+		},
+		AuditAnnotations: auditAnnotations,
+	}
+	return json.Marshal(response)
+}
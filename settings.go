@@ -4,23 +4,181 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
 	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
 )
 
 type ResourceConfiguration struct {
-	MaxLimit       resource.Quantity `json:"maxLimit"`
-	DefaultRequest resource.Quantity `json:"defaultRequest"`
-	DefaultLimit   resource.Quantity `json:"defaultLimit"`
-	IgnoreValues   bool              `json:"ignoreValues,omitempty"`
+	MaxLimit   resource.Quantity `json:"maxLimit"`
+	MinLimit   resource.Quantity `json:"minLimit"`
+	MinRequest resource.Quantity `json:"minRequest"`
+	MaxRequest resource.Quantity `json:"maxRequest"`
+	// Optional bounds of the limit divided by the request, like the
+	// maxLimitRequestRatio from the LimitRange
+	MaxLimitRequestRatio resource.Quantity `json:"maxLimitRequestRatio"`
+	MinLimitRequestRatio resource.Quantity `json:"minLimitRequestRatio"`
+	DefaultRequest       resource.Quantity `json:"defaultRequest"`
+	DefaultLimit         resource.Quantity `json:"defaultLimit"`
+	IgnoreValues         bool              `json:"ignoreValues,omitempty"`
+	// Whether the limit is required, forbidden or removed. Defaults to
+	// required
+	LimitMode string `json:"limitMode,omitempty"`
+	// What to do with the limits exceeding the max limit. Defaults to reject
+	OnExceed string `json:"onExceed,omitempty"`
+	// How the missing requests and limits are computed. The default values
+	// are used by default
+	RequestDerivation string `json:"requestDerivation,omitempty"`
+	LimitDerivation   string `json:"limitDerivation,omitempty"`
+	// Factors used by the derivation strategies: the request is the limit
+	// multiplied by the requestFactor, the limit is the request multiplied
+	// by the limitFactor
+	RequestFactor resource.Quantity `json:"requestFactor"`
+	LimitFactor   resource.Quantity `json:"limitFactor"`
+	// Bounds of the growth of the limits and of the requests of the
+	// containers updated by an UPDATE request: the new value cannot exceed
+	// the old one multiplied by the maxGrowthFactor, or increased by the
+	// maxGrowthDelta. Zero values are not enforced
+	MaxGrowthFactor resource.Quantity `json:"maxGrowthFactor"`
+	MaxGrowthDelta  resource.Quantity `json:"maxGrowthDelta"`
+	// What to do with the values growing more than allowed. Defaults to
+	// reject
+	OnGrowthExceed string `json:"onGrowthExceed,omitempty"`
+	// skipDefaults disables the mutation with the default values. It is set
+	// when the pod level resources already define the resource
+	skipDefaults bool
+}
+
+// How the policy handles the limits of a resource
+const (
+	// The limit is required, the default limit is used when it is missing
+	LimitModeRequired = "required"
+	// The limit is not allowed. The containers defining it are rejected
+	LimitModeForbid = "forbid"
+	// The limit is not allowed. It is removed from the containers defining it
+	LimitModeStrip = "strip"
+)
+
+// Actions taken when a container limit exceeds the max limit
+const (
+	// Reject the container
+	OnExceedReject = "reject"
+	// Lower the limit to the max limit, and the request to the new limit
+	// when it is greater than it
+	OnExceedClamp = "clamp"
+)
+
+// Enforcement modes of the policy
+const (
+	// Reject the requests with violations
+	ModeEnforce = "enforce"
+	// Accept the requests with violations, returning them as warnings
+	ModeWarn = "warn"
+	// Accept the requests with violations, recording them in the audit
+	// annotations
+	ModeAudit = "audit"
+)
+
+// Actions that can be taken for a kind of container
+const (
+	// Validate the container and mutate it with the default values when needed
+	ContainerActionDefault = "default"
+	// Validate the container, but never mutate it. Containers which would
+	// require mutation are rejected
+	ContainerActionValidate = "validate"
+	// Do not check the container at all
+	ContainerActionSkip = "skip"
+)
+
+// ContainerKindConfiguration defines how the policy handles a kind of
+// container (init, sidecar or ephemeral containers)
+type ContainerKindConfiguration struct {
+	Action string `json:"action"`
 }
 
 type Settings struct {
-	Cpu          *ResourceConfiguration `json:"cpu,omitempty"`
-	Memory       *ResourceConfiguration `json:"memory,omitempty"`
-	IgnoreImages []string               `json:"ignoreImages,omitempty"`
+	Cpu    *ResourceConfiguration `json:"cpu,omitempty"`
+	Memory *ResourceConfiguration `json:"memory,omitempty"`
+	// Configuration of any other resource, like ephemeral-storage, hugepages
+	// or extended resources, indexed by resource name
+	Resources           map[string]*ResourceConfiguration `json:"resources,omitempty"`
+	IgnoreImages        []string                          `json:"ignoreImages,omitempty"`
+	InitContainers      *ContainerKindConfiguration       `json:"initContainers,omitempty"`
+	SidecarContainers   *ContainerKindConfiguration       `json:"sidecarContainers,omitempty"`
+	EphemeralContainers *ContainerKindConfiguration       `json:"ephemeralContainers,omitempty"`
+	Pod                 *PodConfiguration                 `json:"pod,omitempty"`
+	Qos                 *QosConfiguration                 `json:"qos,omitempty"`
+	// Requests exempted from the validation, by namespace or by the user
+	// sending them. Entries are names or glob patterns. Service accounts are
+	// defined as <namespace>:<name>
+	IgnoreNamespaces      []string `json:"ignoreNamespaces,omitempty"`
+	IgnoreServiceAccounts []string `json:"ignoreServiceAccounts,omitempty"`
+	IgnoreUsers           []string `json:"ignoreUsers,omitempty"`
+	IgnoreGroups          []string `json:"ignoreGroups,omitempty"`
+	// Resource configurations merged over the global ones for the objects
+	// of some namespaces. The first matching override is used
+	Overrides []NamespaceOverride `json:"overrides,omitempty"`
+	// Resource configurations merged over the global ones for the pods
+	// matching the selectors. Profiles are applied after the overrides
+	Profiles []ResourceProfile `json:"profiles,omitempty"`
+	// Resource configurations merged over the ones of the pod for the
+	// containers using some images. The first matching profile is used
+	ImageProfiles []ImageProfile `json:"imageProfiles,omitempty"`
+	// Resource configurations merged over the ones of the pod for the
+	// containers with some names. The first matching profile is used. It is
+	// merged over the matching image profile
+	ContainerProfiles []ContainerProfile `json:"containerProfiles,omitempty"`
+	// How violations are handled. Defaults to enforce
+	Mode string `json:"mode,omitempty"`
+	// Do not mutate the containers. Containers which would require mutation
+	// are reported as violations
+	DisableMutation bool `json:"disableMutation,omitempty"`
+	// How the containers whose image and resources are not changed by an
+	// UPDATE request are handled. Defaults to enforce
+	UnchangedContainers string `json:"unchangedContainers,omitempty"`
+	// Where the PodSpecs, or the lists of containers, of the custom resources
+	// are found. The kinds not listed here must be known by the policy
+	CustomResources []CustomResource `json:"customResources,omitempty"`
+	// How the objects of the kinds not supported by the policy are handled.
+	// Defaults to reject
+	UnsupportedKinds string `json:"unsupportedKinds,omitempty"`
+	// Allows users to exempt their workloads with an annotation
+	ExemptionAnnotation *ExemptionAnnotationConfiguration `json:"exemptionAnnotation,omitempty"`
+	// exemptContainers are the names of the containers exempted by the
+	// exemption annotation of the object under validation
+	exemptContainers []string
+	// oldContainers are the containers of the old object of the UPDATE
+	// request under validation, indexed by name
+	oldContainers map[string]*corev1.Container
+	// unchangedContainers are the names of the containers whose image and
+	// resources are not changed by the UPDATE request under validation.
+	// podUnchanged is true when no container and no pod level resource is
+	// changed
+	unchangedContainers []string
+	podUnchanged        bool
+	// ephemeralContainersOnly is set for the updates of the
+	// ephemeralcontainers subresource, which cannot change the other
+	// containers
+	ephemeralContainersOnly bool
+}
+
+// PodResourceConfiguration defines the bounds of the total amount of a
+// resource used by a pod. Zero values are not enforced
+type PodResourceConfiguration struct {
+	MaxLimit   resource.Quantity `json:"maxLimit"`
+	MaxRequest resource.Quantity `json:"maxRequest"`
+}
+
+// PodConfiguration defines the pod level bounds, indexed by resource name
+type PodConfiguration struct {
+	Resources map[string]*PodResourceConfiguration `json:"resources,omitempty"`
+	// Do not apply the container default values for the resources defined
+	// by the pod level resources (the resources field of the PodSpec)
+	SkipContainerDefaults bool `json:"skipContainerDefaults,omitempty"`
 }
 
 type AllValuesAreZeroError struct{}
@@ -29,12 +187,89 @@ func (e AllValuesAreZeroError) Error() string {
 	return "all the quantities must be defined"
 }
 
-func (s *Settings) shouldIgnoreCpuValues() bool {
-	return s.Cpu != nil && (s.Cpu.IgnoreValues || (!s.Cpu.IgnoreValues && s.Cpu.allValuesAreZero()))
+// resourceConfiguration returns the configuration of the given resource, or
+// nil when the resource is not verified by the policy. The cpu and memory
+// fields take precedence over the resources field for backward compatibility
+func (s *Settings) resourceConfiguration(resourceName string) *ResourceConfiguration {
+	if resourceName == "cpu" && s.Cpu != nil {
+		return s.Cpu
+	}
+	if resourceName == "memory" && s.Memory != nil {
+		return s.Memory
+	}
+	return s.Resources[resourceName]
+}
+
+// resourceNames returns the sorted names of all the resources verified by the
+// policy. Sorting keeps the validation order deterministic
+func (s *Settings) resourceNames() []string {
+	names := []string{}
+	for resourceName, resourceConfig := range s.Resources {
+		if resourceConfig != nil {
+			names = append(names, resourceName)
+		}
+	}
+	if s.Cpu != nil && s.Resources["cpu"] == nil {
+		names = append(names, "cpu")
+	}
+	if s.Memory != nil && s.Resources["memory"] == nil {
+		names = append(names, "memory")
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (s *Settings) shouldIgnoreValues(resourceName string) bool {
+	resourceConfig := s.resourceConfiguration(resourceName)
+	return resourceConfig != nil && (resourceConfig.IgnoreValues || (!resourceConfig.IgnoreValues && resourceConfig.allValuesAreZero()))
+}
+
+// withoutContainerDefaults returns a copy of the settings where the default
+// values of the given resources are not applied to the containers
+func (s *Settings) withoutContainerDefaults(resourceNames []string) *Settings {
+	settings := *s
+	settings.Cpu, settings.Memory = nil, nil
+	settings.Resources = map[string]*ResourceConfiguration{}
+	for _, resourceName := range s.resourceNames() {
+		resourceConfig := *s.resourceConfiguration(resourceName)
+		if slices.Contains(resourceNames, resourceName) {
+			resourceConfig.skipDefaults = true
+		}
+		settings.Resources[resourceName] = &resourceConfig
+	}
+	return &settings
+}
+
+// forbidsLimits returns true when the limits of any resource are not allowed
+func (s *Settings) forbidsLimits() bool {
+	for _, resourceName := range s.resourceNames() {
+		if s.resourceConfiguration(resourceName).limitsForbidden() {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldIgnoreAllValues returns true when all the resources verified by the
+// policy are only checked for presence
+func (s *Settings) shouldIgnoreAllValues() bool {
+	resourceNames := s.resourceNames()
+	for _, resourceName := range resourceNames {
+		if !s.shouldIgnoreValues(resourceName) {
+			return false
+		}
+	}
+	return len(resourceNames) > 0
 }
 
-func (s *Settings) shouldIgnoreMemoryValues() bool {
-	return s.Memory != nil && (s.Memory.IgnoreValues || (!s.Memory.IgnoreValues && s.Memory.allValuesAreZero()))
+// isOvercommitForbidden returns true for the resources where Kubernetes
+// requires the request to be equal to the limit: hugepages and extended
+// resources
+func isOvercommitForbidden(resourceName string) bool {
+	if strings.HasPrefix(resourceName, "hugepages-") {
+		return true
+	}
+	return strings.Contains(resourceName, "/") && !strings.Contains(resourceName, "kubernetes.io/")
 }
 
 func (r *ResourceConfiguration) valid() error {
@@ -43,43 +278,327 @@ func (r *ResourceConfiguration) valid() error {
 		return AllValuesAreZeroError{}
 	}
 
-	if r.MaxLimit.Cmp(r.DefaultLimit) < 0 ||
-		r.MaxLimit.Cmp(r.DefaultRequest) < 0 {
-		return fmt.Errorf("default values cannot be greater than the max limit")
+	switch r.OnExceed {
+	case "", OnExceedReject, OnExceedClamp:
+	default:
+		return fmt.Errorf("invalid onExceed value '%s'. Valid values are: %s, %s", r.OnExceed, OnExceedReject, OnExceedClamp)
+	}
+
+	if err := r.validGrowth(); err != nil {
+		return err
+	}
+
+	switch r.LimitMode {
+	case "", LimitModeRequired:
+	case LimitModeForbid, LimitModeStrip:
+		// The checks of the values depending on the limits are skipped
+		if err := r.validLimitRequestRatioBounds(); err != nil {
+			return err
+		}
+		if err := r.validDerivations(); err != nil {
+			return err
+		}
+		return r.validWithoutLimits()
+	default:
+		return fmt.Errorf("invalid limitMode value '%s'. Valid values are: %s, %s, %s", r.LimitMode, LimitModeRequired, LimitModeForbid, LimitModeStrip)
+	}
+
+	// The max limit is required by the default limit. Otherwise, it is
+	// optional and the limits are not bounded when it is missing
+	if r.limitsBounded() {
+		if r.MaxLimit.Cmp(r.DefaultLimit) < 0 ||
+			r.MaxLimit.Cmp(r.DefaultRequest) < 0 {
+			return fmt.Errorf("default values cannot be greater than the max limit")
+		}
+
+		if r.MaxLimit.Cmp(r.MinLimit) < 0 ||
+			r.MaxLimit.Cmp(r.MinRequest) < 0 {
+			return fmt.Errorf("min values cannot be greater than the max limit")
+		}
+	} else if r.OnExceed == OnExceedClamp {
+		return fmt.Errorf("a max limit is required when onExceed is %s", OnExceedClamp)
+	}
+
+	if err := r.validRequests(); err != nil {
+		return err
+	}
+
+	if err := r.validLimitRequestRatios(); err != nil {
+		return err
+	}
+
+	if err := r.validDerivations(); err != nil {
+		return err
+	}
+
+	if !r.DefaultLimit.IsZero() && r.DefaultLimit.Cmp(r.MinLimit) < 0 {
+		return fmt.Errorf("default limit cannot be less than the min limit")
 	}
 
 	return nil
 }
 
+func (r *ResourceConfiguration) validRequests() error {
+	// The max request is optional. When it is not defined, requests are
+	// not bounded
+	if !r.MaxRequest.IsZero() &&
+		(r.MaxRequest.Cmp(r.DefaultRequest) < 0 || r.MaxRequest.Cmp(r.MinRequest) < 0) {
+		return fmt.Errorf("default and min request values cannot be greater than the max request")
+	}
+
+	if !r.DefaultRequest.IsZero() && r.DefaultRequest.Cmp(r.MinRequest) < 0 {
+		return fmt.Errorf("default request cannot be less than the min request")
+	}
+
+	return nil
+}
+
+// validWithoutLimits checks the configuration of a resource whose limits are
+// not allowed. The limit values are ignored, so they can be inherited from
+// the configuration the limit mode is merged over
+func (r *ResourceConfiguration) validWithoutLimits() error {
+	if r.DefaultRequest.IsZero() && !r.IgnoreValues {
+		return fmt.Errorf("a default request is required when the limit mode is %s", r.LimitMode)
+	}
+	return r.validRequests()
+}
+
+// limitsForbidden returns true when the containers cannot define the limit
+// of the resource
+func (r *ResourceConfiguration) limitsForbidden() bool {
+	return r.LimitMode == LimitModeForbid || r.LimitMode == LimitModeStrip
+}
+
+// validLimitRequestRatioBounds checks the limit request ratio bounds, without
+// checking the default values against them
+func (r *ResourceConfiguration) validLimitRequestRatioBounds() error {
+	one := resource.MustParse("1")
+	if !r.MaxLimitRequestRatio.IsZero() && r.MaxLimitRequestRatio.Cmp(one) < 0 {
+		return fmt.Errorf("max limit request ratio cannot be less than 1")
+	}
+	if !r.MinLimitRequestRatio.IsZero() && r.MinLimitRequestRatio.Cmp(one) < 0 {
+		return fmt.Errorf("min limit request ratio cannot be less than 1")
+	}
+	if !r.MaxLimitRequestRatio.IsZero() && r.MaxLimitRequestRatio.Cmp(r.MinLimitRequestRatio) < 0 {
+		return fmt.Errorf("min limit request ratio cannot be greater than the max limit request ratio")
+	}
+	return nil
+}
+
+func (r *ResourceConfiguration) validLimitRequestRatios() error {
+	if err := r.validLimitRequestRatioBounds(); err != nil {
+		return err
+	}
+	if !r.DefaultLimit.IsZero() && !r.DefaultRequest.IsZero() {
+		if !r.MaxLimitRequestRatio.IsZero() && cmpLimitRequestRatio(r.DefaultLimit, r.DefaultRequest, r.MaxLimitRequestRatio) > 0 {
+			return fmt.Errorf("default limit to default request ratio cannot be greater than the max limit request ratio")
+		}
+		if !r.MinLimitRequestRatio.IsZero() && cmpLimitRequestRatio(r.DefaultLimit, r.DefaultRequest, r.MinLimitRequestRatio) < 0 {
+			return fmt.Errorf("default limit to default request ratio cannot be less than the min limit request ratio")
+		}
+	}
+	return nil
+}
+
+func (p *PodConfiguration) valid() error {
+	if len(p.Resources) == 0 && !p.SkipContainerDefaults {
+		return fmt.Errorf("at least one resource must be defined")
+	}
+	for resourceName, resourceConfig := range p.Resources {
+		if resourceConfig == nil || (resourceConfig.MaxLimit.IsZero() && resourceConfig.MaxRequest.IsZero()) {
+			return fmt.Errorf("%s: at least one of maxLimit or maxRequest must be defined", resourceName)
+		}
+		if resourceConfig.MaxLimit.Sign() < 0 || resourceConfig.MaxRequest.Sign() < 0 {
+			return fmt.Errorf("%s: values cannot be negative", resourceName)
+		}
+	}
+	return nil
+}
+
+// validPodLimitModes checks that the pod max limits are not defined for the
+// resources whose limits are not allowed. A pod with containers without a
+// limit is not bounded, so the pod max limit could never be enforced
+func (s *Settings) validPodLimitModes() error {
+	if s.Pod == nil {
+		return nil
+	}
+	for _, resourceName := range s.resourceNames() {
+		resourceConfig := s.resourceConfiguration(resourceName)
+		podConfig := s.Pod.Resources[resourceName]
+		if podConfig != nil && !podConfig.MaxLimit.IsZero() && resourceConfig.limitsForbidden() {
+			return fmt.Errorf("%s: a maxLimit cannot be defined when the limit mode is %s", resourceName, resourceConfig.LimitMode)
+		}
+	}
+	return nil
+}
+
+func (c *ContainerKindConfiguration) valid() error {
+	switch c.Action {
+	case "", ContainerActionDefault, ContainerActionValidate, ContainerActionSkip:
+		return nil
+	}
+	return fmt.Errorf("invalid action '%s'. Valid values are: %s, %s, %s", c.Action, ContainerActionDefault, ContainerActionValidate, ContainerActionSkip)
+}
+
+// actionOrDefault returns the configured action, or the given fallback when the
+// configuration is not defined
+func (c *ContainerKindConfiguration) actionOrDefault(fallback string) string {
+	if c == nil || c.Action == "" {
+		return fallback
+	}
+	return c.Action
+}
+
+// Init containers are validated and mutated like regular containers, unless
+// configured otherwise
+func (s *Settings) initContainersAction() string {
+	return s.InitContainers.actionOrDefault(ContainerActionDefault)
+}
+
+// Sidecar containers are validated and mutated like regular containers, unless
+// configured otherwise
+func (s *Settings) sidecarContainersAction() string {
+	return s.SidecarContainers.actionOrDefault(ContainerActionDefault)
+}
+
+// mode returns the configured enforcement mode, enforce by default
+func (s *Settings) mode() string {
+	if s.Mode == "" {
+		return ModeEnforce
+	}
+	return s.Mode
+}
+
+// Kubernetes does not allow resources to be set on ephemeral containers.
+// Therefore, they are skipped unless configured otherwise
+func (s *Settings) ephemeralContainersAction() string {
+	return s.EphemeralContainers.actionOrDefault(ContainerActionSkip)
+}
+
+// The subresource used to add the ephemeral containers to a pod
+const ephemeralContainersSubresource = "ephemeralcontainers"
+
+// withEphemeralContainersOnly returns a copy of the settings where only the
+// ephemeral containers are checked, and the pod is never mutated
+func (s *Settings) withEphemeralContainersOnly() *Settings {
+	settings := *s
+	settings.ephemeralContainersOnly = true
+	return &settings
+}
+
 func (r *ResourceConfiguration) allValuesAreZero() bool {
-	return r.MaxLimit.IsZero() && r.DefaultLimit.IsZero() && r.DefaultRequest.IsZero()
+	return r.MaxLimit.IsZero() && r.DefaultLimit.IsZero() && r.DefaultRequest.IsZero() &&
+		r.MaxRequest.IsZero() && r.MinRequest.IsZero() && r.MinLimit.IsZero() &&
+		r.MaxLimitRequestRatio.IsZero() && r.MinLimitRequestRatio.IsZero()
+}
+
+// limitsBounded returns true when the limits of the resource are bounded by
+// the max limit. The max limit can be omitted when no default limit is
+// defined, like when only the requests are bounded
+func (r *ResourceConfiguration) limitsBounded() bool {
+	return !r.MaxLimit.IsZero() || !r.DefaultLimit.IsZero()
 }
 
 func (s *Settings) Valid() error {
-	if s.Cpu == nil && s.Memory == nil {
+	resourceNames := s.resourceNames()
+	if len(resourceNames) == 0 && s.Pod == nil && s.Qos == nil && len(s.Overrides) == 0 && len(s.Profiles) == 0 && len(s.ImageProfiles) == 0 && len(s.ContainerProfiles) == 0 {
 		return fmt.Errorf("no settings provided. At least one resource limit or request must be verified")
 	}
-	var cpuError, memoryError error
-	if s.Cpu != nil {
-		cpuError = s.Cpu.valid()
-		if cpuError != nil {
-			cpuError = errors.Join(fmt.Errorf("invalid cpu settings"), cpuError)
+	if s.Pod != nil {
+		if err := s.Pod.valid(); err != nil {
+			return errors.Join(fmt.Errorf("invalid pod settings"), err)
 		}
 	}
-	if s.Memory != nil {
-		memoryError = s.Memory.valid()
-		if memoryError != nil {
-			memoryError = errors.Join(fmt.Errorf("invalid memory settings"), memoryError)
+	if err := s.validPodLimitModes(); err != nil {
+		return errors.Join(fmt.Errorf("invalid pod settings"), err)
+	}
+	if s.Qos != nil {
+		if err := s.Qos.valid(); err != nil {
+			return errors.Join(fmt.Errorf("invalid qos settings"), err)
 		}
 	}
-	if cpuError != nil || memoryError != nil {
-		// user want to validate only one type of resource. The other one should be ignored
-		if (cpuError == nil && errors.Is(memoryError, AllValuesAreZeroError{})) || (memoryError == nil && errors.Is(cpuError, AllValuesAreZeroError{})) {
-			return nil
+	if s.Cpu != nil && s.Resources["cpu"] != nil {
+		return fmt.Errorf("cpu settings cannot be defined in both the cpu and the resources fields")
+	}
+	if s.Memory != nil && s.Resources["memory"] != nil {
+		return fmt.Errorf("memory settings cannot be defined in both the memory and the resources fields")
+	}
+	switch s.Mode {
+	case "", ModeEnforce, ModeWarn, ModeAudit:
+	default:
+		return fmt.Errorf("invalid mode '%s'. Valid values are: %s, %s, %s", s.Mode, ModeEnforce, ModeWarn, ModeAudit)
+	}
+	switch s.UnsupportedKinds {
+	case "", UnsupportedKindsReject, UnsupportedKindsAccept, UnsupportedKindsWarn:
+	default:
+		return fmt.Errorf("invalid unsupportedKinds value '%s'. Valid values are: %s, %s, %s", s.UnsupportedKinds, UnsupportedKindsReject, UnsupportedKindsAccept, UnsupportedKindsWarn)
+	}
+	switch s.UnchangedContainers {
+	case "", UnchangedContainersEnforce, UnchangedContainersWarn, UnchangedContainersSkip:
+	default:
+		return fmt.Errorf("invalid unchangedContainers value '%s'. Valid values are: %s, %s, %s", s.UnchangedContainers, UnchangedContainersEnforce, UnchangedContainersWarn, UnchangedContainersSkip)
+	}
+	if err := s.validExemptions(); err != nil {
+		return err
+	}
+	if err := validImagePatterns(s.IgnoreImages); err != nil {
+		return errors.Join(fmt.Errorf("invalid ignoreImages settings"), err)
+	}
+	containerKinds := []struct {
+		name   string
+		config *ContainerKindConfiguration
+	}{
+		{"initContainers", s.InitContainers},
+		{"sidecarContainers", s.SidecarContainers},
+		{"ephemeralContainers", s.EphemeralContainers},
+	}
+	for _, kind := range containerKinds {
+		if kind.config == nil {
+			continue
+		}
+		if err := kind.config.valid(); err != nil {
+			return errors.Join(fmt.Errorf("invalid %s settings", kind.name), err)
 		}
-		return errors.Join(cpuError, memoryError)
 	}
-	return nil
+	if s.EphemeralContainers != nil && s.EphemeralContainers.Action == ContainerActionDefault {
+		return errors.Join(fmt.Errorf("invalid ephemeralContainers settings"), fmt.Errorf("the resources of the ephemeral containers cannot be set. Valid actions are: %s, %s", ContainerActionValidate, ContainerActionSkip))
+	}
+	resourceErrors := []error{}
+	allValuesAreZeroErrors := 0
+	for _, resourceName := range resourceNames {
+		resourceConfig := s.resourceConfiguration(resourceName)
+		err := resourceConfig.valid()
+		if err == nil && isOvercommitForbidden(resourceName) && resourceConfig.DefaultLimit.Cmp(resourceConfig.DefaultRequest) != 0 &&
+			!resourceConfig.DefaultLimit.IsZero() && !resourceConfig.DefaultRequest.IsZero() {
+			err = fmt.Errorf("%s cannot be overcommitted. The default request must be equal to the default limit", resourceName)
+		}
+		if err == nil && isOvercommitForbidden(resourceName) && resourceConfig.limitsForbidden() {
+			err = fmt.Errorf("%s limits are required by Kubernetes. The limit mode cannot be %s", resourceName, resourceConfig.LimitMode)
+		}
+		if err != nil {
+			if errors.Is(err, AllValuesAreZeroError{}) {
+				allValuesAreZeroErrors++
+			}
+			resourceErrors = append(resourceErrors, errors.Join(fmt.Errorf("invalid %s settings", resourceName), err))
+		}
+	}
+	// user want to validate only some types of resource. The others should be ignored
+	if len(resourceErrors) > 0 && (len(resourceErrors) > allValuesAreZeroErrors || len(resourceErrors) == len(resourceNames)) {
+		return errors.Join(resourceErrors...)
+	}
+	if err := s.validOverrides(); err != nil {
+		return err
+	}
+	if err := s.validProfiles(); err != nil {
+		return err
+	}
+	if err := s.validImageProfiles(); err != nil {
+		return err
+	}
+	if err := s.validContainerProfiles(); err != nil {
+		return err
+	}
+	return s.validCustomResources()
 }
 
 func NewSettingsFromValidationReq(validationReq *kubewarden_protocol.ValidationRequest) (Settings, error) {
@@ -42,6 +42,18 @@ func TestParsingResourceConfiguration(t *testing.T) {
 		{"invalid request suffix", []byte(`{"maxLimit": "3m", "defaultLimit": "2m", "defaultRequest": "1x"}`), "quantities must match the regular expression"},
 		{"defaults greater than max limit", []byte(`{"maxLimit": "2m", "defaultRequest": "3m", "defaultLimit": "4m"}`), "default values cannot be greater than the max limit"},
 		{"valid resource configuration", []byte(`{"maxLimit": "4G", "defaultLimit": "2G", "defaultRequest": "1G"}`), ""},
+		{"valid min values", []byte(`{"maxLimit": "4G", "minLimit": "1G", "minRequest": "500M", "defaultLimit": "2G", "defaultRequest": "1G"}`), ""},
+		{"min values greater than max limit", []byte(`{"maxLimit": "4G", "minLimit": "5G", "defaultLimit": "2G", "defaultRequest": "1G"}`), "min values cannot be greater than the max limit"},
+		{"min request greater than max limit", []byte(`{"maxLimit": "4G", "minRequest": "5G", "defaultLimit": "2G", "defaultRequest": "1G"}`), "min values cannot be greater than the max limit"},
+		{"default limit less than min limit", []byte(`{"maxLimit": "4G", "minLimit": "3G", "defaultLimit": "2G", "defaultRequest": "1G"}`), "default limit cannot be less than the min limit"},
+		{"valid max request", []byte(`{"maxLimit": "4G", "maxRequest": "2G", "defaultLimit": "2G", "defaultRequest": "1G"}`), ""},
+		{"default request greater than max request", []byte(`{"maxLimit": "4G", "maxRequest": "1G", "defaultLimit": "2G", "defaultRequest": "2G"}`), "default and min request values cannot be greater than the max request"},
+		{"min request greater than max request", []byte(`{"maxLimit": "4G", "maxRequest": "1G", "minRequest": "2G", "defaultLimit": "2G", "defaultRequest": "1G"}`), "default and min request values cannot be greater than the max request"},
+		{"valid limit request ratios", []byte(`{"maxLimit": "4", "maxLimitRequestRatio": "4", "minLimitRequestRatio": "1.5", "defaultLimit": "2", "defaultRequest": "1"}`), ""},
+		{"max limit request ratio less than 1", []byte(`{"maxLimit": "4", "maxLimitRequestRatio": "0.5", "defaultLimit": "2", "defaultRequest": "1"}`), "max limit request ratio cannot be less than 1"},
+		{"min limit request ratio greater than max ratio", []byte(`{"maxLimit": "4", "maxLimitRequestRatio": "2", "minLimitRequestRatio": "3", "defaultLimit": "2", "defaultRequest": "1"}`), "min limit request ratio cannot be greater than the max limit request ratio"},
+		{"default values exceeding the max limit request ratio", []byte(`{"maxLimit": "4", "maxLimitRequestRatio": "1500m", "defaultLimit": "2", "defaultRequest": "1"}`), "default limit to default request ratio cannot be greater than the max limit request ratio"},
+		{"default request less than min request", []byte(`{"maxLimit": "4G", "minRequest": "2G", "defaultLimit": "2G", "defaultRequest": "1G"}`), "default request cannot be less than the min request"},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -86,6 +98,92 @@ func TestParsingSettings(t *testing.T) {
 		{"invalid memory settings", []byte(`{"cpu": {"maxLimit": "2m", "defaultRequest": "1m", "defaultLimit": "1m"}, "memory":{ "defaultLimit": "2G", "defaultRequest": "3G", "maxLimit": "1G"}, "ignoreImages": ["image:latest"]}`), "default values cannot be greater than the max limit"},
 		{"valid settings with empty memory settings", []byte(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "memory":{"ignoreValues": false}, "ignoreImages": ["image:latest"]}`), ""},
 		{"valid settings with empty cpu settings", []byte(`{"cpu": {"ignoreValues": false}, "memory":{ "defaultLimit": "200M", "defaultRequest": "100M", "maxLimit": "500M", "ignoreValues": false}, "ignoreImages": ["image:latest"]}`), ""},
+		{"valid generic resources settings", []byte(`{"resources": {"ephemeral-storage": {"maxLimit": "10Gi", "defaultRequest": "1Gi", "defaultLimit": "2Gi"}, "hugepages-2Mi": {"maxLimit": "1Gi", "defaultRequest": "100Mi", "defaultLimit": "100Mi"}, "example.com/fpga": {"ignoreValues": true}}}`), ""},
+		{"valid cpu settings in the generic resources settings", []byte(`{"resources": {"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}}}`), ""},
+		{"invalid cpu settings defined twice", []byte(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "resources": {"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}}}`), "cpu settings cannot be defined in both the cpu and the resources fields"},
+		{"invalid generic resource settings", []byte(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "resources": {"ephemeral-storage": {"maxLimit": "1Gi", "defaultRequest": "2Gi", "defaultLimit": "2Gi"}}}`), "invalid ephemeral-storage settings\ndefault values cannot be greater than the max limit"},
+		{"overcommitted hugepages settings", []byte(`{"resources": {"hugepages-1Gi": {"maxLimit": "4Gi", "defaultRequest": "1Gi", "defaultLimit": "2Gi"}}}`), "hugepages-1Gi cannot be overcommitted"},
+		{"overcommitted extended resource settings", []byte(`{"resources": {"example.com/fpga": {"maxLimit": "4", "defaultRequest": "1", "defaultLimit": "2"}}}`), "example.com/fpga cannot be overcommitted"},
+		{"valid pod settings only", []byte(`{"pod": {"resources": {"cpu": {"maxLimit": "4"}, "memory": {"maxRequest": "4Gi"}}}}`), ""},
+		{"valid pod settings skipping container defaults only", []byte(`{"pod": {"skipContainerDefaults": true}}`), ""},
+		{"invalid empty pod settings", []byte(`{"pod": {}}`), "invalid pod settings\nat least one resource must be defined"},
+		{"invalid pod resource settings", []byte(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "pod": {"resources": {"cpu": {}}}}`), "invalid pod settings\ncpu: at least one of maxLimit or maxRequest must be defined"},
+		{"valid container kinds settings", []byte(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "initContainers": {"action": "validate"}, "sidecarContainers": {"action": "default"}, "ephemeralContainers": {"action": "skip"}}`), ""},
+		{"invalid init containers action", []byte(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "initContainers": {"action": "foo"}}`), "invalid initContainers settings\ninvalid action 'foo'"},
+		{"ephemeral containers mutation", []byte(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "ephemeralContainers": {"action": "default"}}`), "invalid ephemeralContainers settings\nthe resources of the ephemeral containers cannot be set. Valid actions are: validate, skip"},
+		{"invalid ephemeral containers action", []byte(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "ephemeralContainers": {"action": "mutate"}}`), "invalid ephemeralContainers settings\ninvalid action 'mutate'"},
+		{"valid namespace overrides", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "overrides": [{"namespaces": ["batch", "team-*"], "cpu": {"maxLimit": "4"}}]}`), ""},
+		{"valid namespace overrides only", []byte(`{"overrides": [{"namespaces": ["batch"], "memory": {"maxLimit": "4Gi", "defaultLimit": "1Gi", "defaultRequest": "1Gi"}}]}`), ""},
+		{"invalid namespace override without namespaces", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "overrides": [{"cpu": {"maxLimit": "4"}}]}`), "invalid overrides[0] settings\nat least one namespace must be defined"},
+		{"invalid namespace override pattern", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "overrides": [{"namespaces": ["team-["], "cpu": {"maxLimit": "4"}}]}`), "invalid namespace pattern 'team-['"},
+		{"invalid merged namespace override", []byte(`{"cpu": {"maxLimit": "4", "defaultRequest": "1", "defaultLimit": "2"}, "overrides": [{"namespaces": ["web"], "cpu": {"maxLimit": "1"}}]}`), "invalid overrides[0] settings\ninvalid cpu settings\ndefault values cannot be greater than the max limit"},
+		{"valid profiles", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "profiles": [{"name": "large", "selector": {"matchLabels": {"size": "large"}}, "cpu": {"maxLimit": "4"}}]}`), ""},
+		{"invalid profile without name", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "profiles": [{"selector": {"matchLabels": {"size": "large"}}}]}`), "invalid profiles[0] settings\nname must be defined"},
+		{"invalid profile without selectors", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "profiles": [{"name": "large", "cpu": {"maxLimit": "4"}}]}`), "at least one of selector or annotationSelector must be defined"},
+		{"invalid profile selector operator", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "profiles": [{"name": "large", "selector": {"matchExpressions": [{"key": "size", "operator": "Equals", "values": ["large"]}]}}]}`), "invalid selector\nmatchExpressions[0]: invalid operator 'Equals'"},
+		{"invalid profile selector values", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "profiles": [{"name": "large", "selector": {"matchExpressions": [{"key": "size", "operator": "In"}]}}]}`), "matchExpressions[0]: values must be defined for the In operator"},
+		{"duplicated profile names", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "profiles": [{"name": "large", "selector": {}}, {"name": "large", "selector": {}}]}`), "invalid profiles[1] settings\nduplicated profile name 'large'"},
+		{"invalid profile merged over a namespace override", []byte(`{"cpu": {"maxLimit": "4", "defaultRequest": "1", "defaultLimit": "1"}, "overrides": [{"namespaces": ["batch"], "cpu": {"defaultLimit": "3"}}], "profiles": [{"name": "small", "selector": {}, "cpu": {"maxLimit": "2"}}]}`), "invalid profiles[0] settings\ninvalid cpu settings\ndefault values cannot be greater than the max limit"},
+		{"valid image patterns", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "ignoreImages": ["nginx", "*/pause:*", "ghcr.io/org/*:v1.?", "nginx@sha256:0123456789abcdef", "regex:^quay\\.io/.*$"]}`), ""},
+		{"invalid image glob", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "ignoreImages": ["ghcr.io/org/app:v[1"]}`), "invalid ignoreImages settings\ninvalid image pattern 'ghcr.io/org/app:v[1'"},
+		{"invalid image regex", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "ignoreImages": ["regex:(nginx"]}`), "invalid ignoreImages settings\ninvalid image regular expression 'regex:(nginx'"},
+		{"valid exemptions", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "ignoreNamespaces": ["kube-system", "platform-*"], "ignoreServiceAccounts": ["ci:builder", "tools:*"], "ignoreUsers": ["admin"], "ignoreGroups": ["sre"]}`), ""},
+		{"invalid namespace exemption", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "ignoreNamespaces": ["kube-["]}`), "invalid ignoreNamespaces settings: invalid pattern 'kube-['"},
+		{"invalid service account exemption", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "ignoreServiceAccounts": ["builder"]}`), "invalid ignoreServiceAccounts settings: invalid service account 'builder'. The expected format is <namespace>:<name>"},
+		{"valid image profiles", []byte(`{"memory": {"maxLimit": "1Gi", "defaultRequest": "256Mi", "defaultLimit": "512Mi"}, "imageProfiles": [{"images": ["*/example/*-jvm:*"], "memory": {"maxLimit": "8Gi", "defaultLimit": "4Gi"}}]}`), ""},
+		{"invalid image profile without images", []byte(`{"memory": {"maxLimit": "1Gi", "defaultRequest": "256Mi", "defaultLimit": "512Mi"}, "imageProfiles": [{"memory": {"maxLimit": "8Gi"}}]}`), "invalid imageProfiles[0] settings\nat least one image must be defined"},
+		{"invalid image profile pattern", []byte(`{"memory": {"maxLimit": "1Gi", "defaultRequest": "256Mi", "defaultLimit": "512Mi"}, "imageProfiles": [{"images": ["regex:(jvm"], "memory": {"maxLimit": "8Gi"}}]}`), "invalid image regular expression 'regex:(jvm'"},
+		{"invalid image profile merged over a profile", []byte(`{"memory": {"maxLimit": "1Gi", "defaultRequest": "256Mi", "defaultLimit": "512Mi"}, "profiles": [{"name": "large", "selector": {}, "memory": {"maxLimit": "16Gi", "defaultLimit": "12Gi"}}], "imageProfiles": [{"images": ["jvm"], "memory": {"maxLimit": "8Gi"}}]}`), "invalid imageProfiles[0] settings\ninvalid memory settings\ndefault values cannot be greater than the max limit"},
+		{"valid container profiles", []byte(`{"cpu": {"maxLimit": "2", "defaultRequest": "500m", "defaultLimit": "1"}, "containerProfiles": [{"names": ["istio-proxy", "linkerd-*"], "cpu": {"maxLimit": "500m", "defaultRequest": "100m", "defaultLimit": "200m"}}]}`), ""},
+		{"invalid container profile without names", []byte(`{"cpu": {"maxLimit": "2", "defaultRequest": "500m", "defaultLimit": "1"}, "containerProfiles": [{"cpu": {"maxLimit": "500m"}}]}`), "invalid containerProfiles[0] settings\nat least one container name must be defined"},
+		{"invalid container profile pattern", []byte(`{"cpu": {"maxLimit": "2", "defaultRequest": "500m", "defaultLimit": "1"}, "containerProfiles": [{"names": ["istio-["], "cpu": {"maxLimit": "500m"}}]}`), "invalid container name pattern 'istio-['"},
+		{"invalid container profile merged over an image profile", []byte(`{"cpu": {"maxLimit": "2", "defaultRequest": "100m", "defaultLimit": "1"}, "imageProfiles": [{"images": ["nginx"], "cpu": {"defaultRequest": "800m"}}], "containerProfiles": [{"names": ["istio-proxy"], "cpu": {"maxLimit": "500m", "defaultLimit": "400m"}}]}`), "invalid containerProfiles[0] settings\ninvalid cpu settings\ndefault values cannot be greater than the max limit"},
+		{"qos in a container profile", []byte(`{"qos": {"allowedClasses": ["Burstable"]}, "containerProfiles": [{"names": ["app"], "qos": {"allowedClasses": ["Guaranteed"]}}]}`), "invalid containerProfiles[0] settings\nthe QoS configuration cannot be defined by container profiles"},
+		{"valid custom resources", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "argoproj.io", "kind": "Rollout", "podSpecPaths": ["spec.template.spec"]}, {"group": "tekton.dev", "version": "v1", "kind": "TaskRun", "containerPaths": ["spec.taskSpec.steps", "spec.taskSpec.sidecars"]}]}`), ""},
+		{"custom resource without kind", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "argoproj.io", "podSpecPaths": ["spec.template.spec"]}]}`), "invalid customResources[0] settings\nthe kind must be defined"},
+		{"custom resource without paths", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "argoproj.io", "kind": "Rollout"}]}`), "invalid customResources[0] settings\nat least one podSpecPaths or containerPaths entry must be defined"},
+		{"custom resource with a PodSpec path not ending in spec", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "example.com", "kind": "Workload", "podSpecPaths": ["spec.podTemplate"]}]}`), "invalid customResources[0] settings\ninvalid podSpecPaths entry 'spec.podTemplate'. The PodSpec must be the spec field of a pod or of a pod template"},
+		{"custom resource with a PodSpec path ending in a spec suffix", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "example.com", "kind": "Workload", "podSpecPaths": ["spec.podspec"]}]}`), "invalid podSpecPaths entry 'spec.podspec'"},
+		{"custom resource with a pod spec", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "example.com", "kind": "Sandbox", "podSpecPaths": ["spec"]}]}`), ""},
+		{"custom resource with a resources field without container paths", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "argoproj.io", "kind": "Rollout", "podSpecPaths": ["spec.template.spec"], "resourcesField": "computeResources"}]}`), "invalid customResources[0] settings\nthe resourcesField is used only by the containerPaths entries"},
+		{"custom resource with an invalid resources field", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "tekton.dev", "kind": "TaskRun", "containerPaths": ["spec.taskSpec.steps"], "resourcesField": "spec.computeResources"}]}`), "invalid customResources[0] settings\ninvalid resourcesField 'spec.computeResources'"},
+		{"custom resource with an invalid path", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "customResources": [{"group": "tekton.dev", "kind": "TaskRun", "containerPaths": ["spec..steps"]}]}`), "invalid customResources[0] settings\ninvalid path 'spec..steps'"},
+		{"valid unsupported kinds action", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "unsupportedKinds": "warn"}`), ""},
+		{"invalid unsupported kinds action", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "unsupportedKinds": "ignore"}`), "invalid unsupportedKinds value 'ignore'. Valid values are: reject, accept, warn"},
+		{"valid request bounds only", []byte(`{"memory": {"maxRequest": "1Gi"}}`), ""},
+		{"valid request bounds next to another resource", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "memory": {"maxRequest": "1Gi", "minRequest": "10Mi"}}`), ""},
+		{"valid min values only", []byte(`{"memory": {"minLimit": "64Mi", "minRequest": "32Mi"}}`), ""},
+		{"valid limit request ratios only", []byte(`{"memory": {"maxLimitRequestRatio": "2", "minLimitRequestRatio": "1"}}`), ""},
+		{"pod max limit with stripped limits", []byte(`{"cpu": {"limitMode": "strip", "defaultRequest": "100m"}, "pod": {"resources": {"cpu": {"maxLimit": "2"}}}}`), "invalid pod settings\ncpu: a maxLimit cannot be defined when the limit mode is strip"},
+		{"pod max request with stripped limits", []byte(`{"cpu": {"limitMode": "strip", "defaultRequest": "100m"}, "pod": {"resources": {"cpu": {"maxRequest": "2"}}}}`), ""},
+		{"pod max limit with limits forbidden by an override", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"}, "pod": {"resources": {"cpu": {"maxLimit": "2"}}}, "overrides": [{"namespaces": ["batch"], "cpu": {"limitMode": "forbid"}}]}`), "invalid overrides[0] settings\ninvalid pod settings\ncpu: a maxLimit cannot be defined when the limit mode is forbid"},
+		{"invalid derivation with stripped limits", []byte(`{"cpu": {"limitMode": "strip", "defaultRequest": "100m", "requestDerivation": "bogus"}}`), "invalid cpu settings\ninvalid requestDerivation value 'bogus'"},
+		{"invalid ratio with forbidden limits", []byte(`{"cpu": {"limitMode": "forbid", "defaultRequest": "100m", "maxLimitRequestRatio": "500m"}}`), "invalid cpu settings\nmax limit request ratio cannot be less than 1"},
+		{"clamp without max limit", []byte(`{"memory": {"maxRequest": "1Gi", "onExceed": "clamp"}}`), "a max limit is required when onExceed is clamp"},
+		{"valid exemption annotation", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "exemptionAnnotation": {"annotation": "example.com/exempt", "requireExpiration": true}}`), ""},
+		{"valid clamp on exceed", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1", "onExceed": "clamp"}}`), ""},
+		{"invalid on exceed value", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1", "onExceed": "ignore"}}`), "invalid cpu settings\ninvalid onExceed value 'ignore'. Valid values are: reject, clamp"},
+		{"valid derivations", []byte(`{"cpu": {"maxLimit": "2", "defaultRequest": "500m", "defaultLimit": "1", "requestDerivation": "limitFactor", "requestFactor": "0.5", "limitDerivation": "requestFactor", "limitFactor": "2"}}`), ""},
+		{"invalid request derivation", []byte(`{"cpu": {"maxLimit": "2", "defaultRequest": "500m", "defaultLimit": "1", "requestDerivation": "limit"}}`), "invalid cpu settings\ninvalid requestDerivation value 'limit'. Valid values are: default, limitFactor, minDefaultLimit"},
+		{"invalid request factor", []byte(`{"cpu": {"maxLimit": "2", "defaultRequest": "500m", "defaultLimit": "1", "requestDerivation": "limitFactor", "requestFactor": "2"}}`), "invalid cpu settings\nthe limitFactor request derivation requires a requestFactor greater than 0 and less than or equal to 1"},
+		{"min default limit derivation without default request", []byte(`{"cpu": {"maxLimit": "2", "defaultLimit": "1", "requestDerivation": "minDefaultLimit"}}`), "invalid cpu settings\nthe minDefaultLimit request derivation requires a defaultRequest"},
+		{"invalid limit factor", []byte(`{"memory": {"maxLimit": "2Gi", "defaultRequest": "512Mi", "defaultLimit": "1Gi", "limitDerivation": "requestFactor", "limitFactor": "0.5"}}`), "invalid memory settings\nthe requestFactor limit derivation requires a limitFactor greater than or equal to 1"},
+		{"valid growth bounds", []byte(`{"cpu": {"maxLimit": "4", "defaultRequest": "500m", "defaultLimit": "1", "maxGrowthFactor": "2", "maxGrowthDelta": "1", "onGrowthExceed": "warn"}}`), ""},
+		{"invalid growth factor", []byte(`{"cpu": {"maxLimit": "4", "defaultRequest": "500m", "defaultLimit": "1", "maxGrowthFactor": "0.5"}}`), "invalid cpu settings\nmaxGrowthFactor must be greater than or equal to 1"},
+		{"negative growth delta", []byte(`{"memory": {"maxLimit": "4Gi", "defaultRequest": "512Mi", "defaultLimit": "1Gi", "maxGrowthDelta": "-1Gi"}}`), "invalid memory settings\nmaxGrowthDelta cannot be negative"},
+		{"invalid on growth exceed value", []byte(`{"cpu": {"maxLimit": "4", "defaultRequest": "500m", "defaultLimit": "1", "onGrowthExceed": "clamp"}}`), "invalid cpu settings\ninvalid onGrowthExceed value 'clamp'. Valid values are: reject, warn"},
+		{"valid cpu limit removal", []byte(`{"cpu": {"defaultRequest": "100m", "maxRequest": "1", "limitMode": "strip"}}`), ""},
+		{"valid cpu limit removal in an override", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "500m", "defaultLimit": "1"}, "overrides": [{"namespaces": ["batch"], "cpu": {"limitMode": "forbid"}}]}`), ""},
+		{"invalid limit mode", []byte(`{"cpu": {"defaultRequest": "100m", "limitMode": "none"}}`), "invalid cpu settings\ninvalid limitMode value 'none'. Valid values are: required, forbid, strip"},
+		{"forbidden limits without default request", []byte(`{"cpu": {"maxLimit": "1", "defaultLimit": "1", "limitMode": "forbid"}}`), "invalid cpu settings\na default request is required when the limit mode is forbid"},
+		{"forbidden hugepages limits", []byte(`{"resources": {"hugepages-2Mi": {"defaultRequest": "100Mi", "limitMode": "strip"}}}`), "invalid hugepages-2Mi settings\nhugepages-2Mi limits are required by Kubernetes. The limit mode cannot be strip"},
+		{"valid qos settings", []byte(`{"qos": {"allowedClasses": ["Guaranteed", "Burstable"]}, "overrides": [{"namespaces": ["critical"], "qos": {"allowedClasses": ["Guaranteed"], "action": "mutate"}}]}`), ""},
+		{"invalid qos class", []byte(`{"qos": {"allowedClasses": ["Critical"]}}`), "invalid qos settings\ninvalid QoS class 'Critical'. Valid values are: Guaranteed, Burstable, BestEffort"},
+		{"qos mutation without the guaranteed class", []byte(`{"qos": {"allowedClasses": ["Burstable"], "action": "mutate"}}`), "invalid qos settings\nthe mutate action requires the Guaranteed class to be allowed"},
+		{"invalid qos in an override", []byte(`{"qos": {"allowedClasses": ["Burstable"]}, "overrides": [{"namespaces": ["critical"], "qos": {"allowedClasses": []}}]}`), "invalid overrides[0] settings\ninvalid qos settings\nat least one QoS class must be allowed"},
+		{"qos in an image profile", []byte(`{"qos": {"allowedClasses": ["Burstable"]}, "imageProfiles": [{"images": ["nginx"], "qos": {"allowedClasses": ["Guaranteed"]}}]}`), "invalid imageProfiles[0] settings\nthe QoS configuration cannot be defined by image profiles"},
+		{"valid warn mode without mutation", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "mode": "warn", "disableMutation": true}`), ""},
+		{"invalid mode", []byte(`{"cpu": {"maxLimit": "1", "defaultRequest": "1", "defaultLimit": "1"}, "mode": "dry-run"}`), "invalid mode 'dry-run'. Valid values are: enforce, warn, audit"},
 		{"invalid settings with empty cpu and memory settings", []byte(`{"cpu": {"ignoreValues": false}, "memory":{"ignoreValues": false}, "ignoreImages": ["image:latest"]}`), "invalid cpu settings\nall the quantities must be defined\ninvalid memory settings\nall the quantities must be defined"},
 	}
 	for _, test := range tests {
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// How the containers not changed by an UPDATE request are handled
+const (
+	// Validate and mutate them like any other container
+	UnchangedContainersEnforce = "enforce"
+	// Validate them, returning their violations as warnings
+	UnchangedContainersWarn = "warn"
+	// Do not check them
+	UnchangedContainersSkip = "skip"
+)
+
+// unchangedContainersAction returns how the containers not changed by an
+// UPDATE request are handled
+func (s *Settings) unchangedContainersAction() string {
+	if s.UnchangedContainers == "" {
+		return UnchangedContainersEnforce
+	}
+	return s.UnchangedContainers
+}
+
+// withUnchangedContainers returns a copy of the settings where the containers
+// with the given names, and the pod level resources when podUnchanged is
+// true, are handled as not changed by the request under validation
+func (s *Settings) withUnchangedContainers(names []string, podUnchanged bool) *Settings {
+	settings := *s
+	settings.unchangedContainers = names
+	settings.podUnchanged = podUnchanged
+	return &settings
+}
+
+// isContainerUnchanged returns true when the container is not changed by the
+// request under validation
+func (s *Settings) isContainerUnchanged(container *corev1.Container) bool {
+	return slices.Contains(s.unchangedContainers, containerName(container.Name))
+}
+
+// isContainerWarnedOnly returns true when the container is not changed by the
+// request under validation, and its violations are returned as warnings. These
+// containers are never mutated, so the updates not touching them do not
+// change the pod template
+func (s *Settings) isContainerWarnedOnly(container *corev1.Container) bool {
+	return s.unchangedContainersAction() == UnchangedContainersWarn && s.isContainerUnchanged(container)
+}
+
+// quantitiesEqual returns true when the quantities are both missing, or have
+// the same value. Quantities which cannot be parsed are compared as strings
+func quantitiesEqual(a, b map[string]*api_resource.Quantity, resourceName string) bool {
+	missingA, missingB := missingResourceQuantity(a, resourceName), missingResourceQuantity(b, resourceName)
+	if missingA || missingB {
+		return missingA == missingB
+	}
+	quantityA, errA := resource.ParseQuantity(string(*a[resourceName]))
+	quantityB, errB := resource.ParseQuantity(string(*b[resourceName]))
+	if errA != nil || errB != nil {
+		return *a[resourceName] == *b[resourceName]
+	}
+	return quantityA.Cmp(quantityB) == 0
+}
+
+// resourcesEqual returns true when the resource requirements define the same
+// limits and requests
+func resourcesEqual(a, b *corev1.ResourceRequirements) bool {
+	if a == nil {
+		a = &corev1.ResourceRequirements{}
+	}
+	if b == nil {
+		b = &corev1.ResourceRequirements{}
+	}
+	resourceNames := slices.Concat(podResourceNames(a), podResourceNames(b))
+	for _, resourceName := range resourceNames {
+		if !quantitiesEqual(a.Limits, b.Limits, resourceName) || !quantitiesEqual(a.Requests, b.Requests, resourceName) {
+			return false
+		}
+	}
+	return true
+}
+
+// containerChanged returns true when the container is not defined in the old
+// list of containers, or its image or its resources are different
+func containerChanged(container *corev1.Container, oldContainers []*corev1.Container) bool {
+	for _, oldContainer := range oldContainers {
+		if containerName(oldContainer.Name) == containerName(container.Name) {
+			return oldContainer.Image != container.Image || !resourcesEqual(oldContainer.Resources, container.Resources)
+		}
+	}
+	return true
+}
+
+// ephemeralContainers returns the ephemeral containers as containers, so they
+// can be compared
+func ephemeralContainers(pod *corev1.PodSpec) []*corev1.Container {
+	containers := []*corev1.Container{}
+	for _, ephemeralContainer := range pod.EphemeralContainers {
+		containers = append(containers, &corev1.Container{
+			Name:      ephemeralContainer.Name,
+			Image:     ephemeralContainer.Image,
+			Resources: ephemeralContainer.Resources,
+		})
+	}
+	return containers
+}
+
+// unchangedContainers compares the PodSpec with the one of the old object.
+// It returns the names of the containers whose image and resources are not
+// changed, and true when no container is changed and the pod level resources
+// are not changed either.
+func unchangedContainers(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements, oldPod *corev1.PodSpec, oldPodResources *corev1.ResourceRequirements) ([]string, bool) {
+	names := []string{}
+	podUnchanged := resourcesEqual(podResources, oldPodResources)
+	containerLists := []struct {
+		containers    []*corev1.Container
+		oldContainers []*corev1.Container
+	}{
+		{pod.Containers, oldPod.Containers},
+		{pod.InitContainers, oldPod.InitContainers},
+		{ephemeralContainers(pod), ephemeralContainers(oldPod)},
+	}
+	for _, list := range containerLists {
+		for _, container := range list.containers {
+			if containerChanged(container, list.oldContainers) {
+				podUnchanged = false
+			} else {
+				names = append(names, containerName(container.Name))
+			}
+		}
+	}
+	return names, podUnchanged
+}
+
+// oldPodSpec returns the PodSpec of the target, and the pod level resources,
+// in the old object of an UPDATE request. It returns a nil PodSpec for the
+// other requests
+func oldPodSpec(validationRequest kubewarden_protocol.ValidationRequest, target podTarget) (*corev1.PodSpec, *corev1.ResourceRequirements, error) {
+	oldObject := strings.TrimSpace(string(validationRequest.Request.OldObject))
+	if validationRequest.Request.Operation != "UPDATE" || oldObject == "" || oldObject == "null" {
+		return nil, nil, nil
+	}
+	validationRequest.Request.Object = validationRequest.Request.OldObject
+	object, err := newObject(validationRequest.Request.Object)
+	if err != nil {
+		return nil, nil, err
+	}
+	return podTargetSpec(validationRequest, object, target)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	apimachinery_pkg_api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestUnchangedContainers(t *testing.T) {
+	oneCore := apimachinery_pkg_api_resource.Quantity("1")
+	thousandMillicores := apimachinery_pkg_api_resource.Quantity("1000m")
+	newContainer := func(name, image, limit string) *corev1.Container {
+		container := newContainerWithCpu(limit, "")
+		container.Name, container.Image = &name, image
+		return container
+	}
+	oldPod := &corev1.PodSpec{
+		Containers: []*corev1.Container{
+			newContainer("app", "app:v1", "1"),
+			newContainer("sidecar", "proxy:v1", "500m"),
+			newContainer("logs", "fluent-bit:3", "200m"),
+		},
+	}
+	tests := []struct {
+		name                 string
+		pod                  *corev1.PodSpec
+		podResources         *corev1.ResourceRequirements
+		oldPodResources      *corev1.ResourceRequirements
+		expectedUnchanged    []string
+		expectedPodUnchanged bool
+	}{
+		{
+			"nothing changed",
+			&corev1.PodSpec{Containers: []*corev1.Container{
+				newContainer("app", "app:v1", "1"),
+				newContainer("sidecar", "proxy:v1", "500m"),
+				newContainer("logs", "fluent-bit:3", "200m"),
+			}},
+			nil, nil,
+			[]string{"app", "sidecar", "logs"}, true,
+		},
+		{
+			"equal quantities with a different format",
+			&corev1.PodSpec{Containers: []*corev1.Container{
+				newContainer("app", "app:v1", "1000m"),
+				newContainer("sidecar", "proxy:v1", "0.5"),
+				newContainer("logs", "fluent-bit:3", "200m"),
+			}},
+			&corev1.ResourceRequirements{Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &thousandMillicores}},
+			&corev1.ResourceRequirements{Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &oneCore}},
+			[]string{"app", "sidecar", "logs"}, true,
+		},
+		{
+			"changed image, changed resources and new container",
+			&corev1.PodSpec{Containers: []*corev1.Container{
+				newContainer("app", "app:v2", "1"),
+				newContainer("sidecar", "proxy:v1", "1"),
+				newContainer("logs", "fluent-bit:3", "200m"),
+				newContainer("debug", "busybox", ""),
+			}},
+			nil, nil,
+			[]string{"logs"}, false,
+		},
+		{
+			"changed pod level resources",
+			&corev1.PodSpec{Containers: []*corev1.Container{
+				newContainer("app", "app:v1", "1"),
+				newContainer("sidecar", "proxy:v1", "500m"),
+				newContainer("logs", "fluent-bit:3", "200m"),
+			}},
+			&corev1.ResourceRequirements{Limits: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &oneCore}},
+			nil,
+			[]string{"app", "sidecar", "logs"}, false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unchanged, podUnchanged := unchangedContainers(test.pod, test.podResources, oldPod, test.oldPodResources)
+			if diff := cmp.Diff(test.expectedUnchanged, unchanged); diff != "" {
+				t.Errorf("invalid unchanged containers (-want +got):\n%s", diff)
+			}
+			if podUnchanged != test.expectedPodUnchanged {
+				t.Errorf("invalid pod unchanged flag. Expected %t, got %t", test.expectedPodUnchanged, podUnchanged)
+			}
+		})
+	}
+}
+
+// updateRequest turns the request into an UPDATE of an old object equal to
+// the new one, except for the image of the given container
+func updateRequest(t *testing.T, changedContainer string) func(*kubewarden_protocol.KubernetesAdmissionRequest) {
+	return func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+		request.Operation = "UPDATE"
+		object := map[string]interface{}{}
+		if err := json.Unmarshal(request.Object, &object); err != nil {
+			t.Fatalf("cannot parse the object: %v", err)
+		}
+		for _, container := range object["spec"].(map[string]interface{})["containers"].([]interface{}) {
+			if container := container.(map[string]interface{}); container["name"] == changedContainer {
+				container["image"] = "old-image:v1"
+			}
+		}
+		oldObject, err := json.Marshal(object)
+		if err != nil {
+			t.Fatalf("cannot marshal the old object: %v", err)
+		}
+		request.OldObject = oldObject
+	}
+}
+
+func TestUnchangedContainersOnUpdate(t *testing.T) {
+	rawSettings := `{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "unchangedContainers": "%s"}`
+	tests := []struct {
+		name             string
+		action           string
+		changedContainer string
+		operation        string
+		expectedAccepted bool
+		expectedMessage  string
+		expectedWarnings int
+	}{
+		{"unchanged containers are enforced by default", "", "", "UPDATE", false, "container 'pause'", 0},
+		{"unchanged containers are skipped", UnchangedContainersSkip, "", "UPDATE", true, "", 0},
+		{"unchanged containers violations are warnings", UnchangedContainersWarn, "", "UPDATE", true, "", 2},
+		{"changed containers are enforced", UnchangedContainersSkip, "mycontainer", "UPDATE", false, "container 'mycontainer'", 0},
+		{"changed containers are enforced with the warn action", UnchangedContainersWarn, "mycontainer", "UPDATE", false, "container 'mycontainer'", 0},
+		{"create requests are enforced", UnchangedContainersSkip, "", "CREATE", false, "container 'pause'", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validateTestRequest(t, "test_data/pod_exceeding_range.json", fmt.Sprintf(rawSettings, test.action), func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+				updateRequest(t, test.changedContainer)(request)
+				request.Operation = test.operation
+			})
+			if response.Accepted != test.expectedAccepted {
+				t.Fatalf("expected accepted to be %t, got %t: %v", test.expectedAccepted, response.Accepted, response.Message)
+			}
+			if test.expectedMessage != "" && (response.Message == nil || !strings.Contains(*response.Message, test.expectedMessage)) {
+				t.Errorf("expected message containing '%s', got %v", test.expectedMessage, response.Message)
+			}
+			if response.Message != nil && test.changedContainer != "" && strings.Contains(*response.Message, "container 'pause'") {
+				t.Errorf("the unchanged container should not be rejected: %s", *response.Message)
+			}
+			if len(response.Warnings) != test.expectedWarnings {
+				t.Errorf("expected %d warnings, got %v", test.expectedWarnings, response.Warnings)
+			}
+		})
+	}
+}
+
+func TestUnchangedContainersAreNotMutatedWithTheWarnAction(t *testing.T) {
+	tests := []struct {
+		name             string
+		requestFile      string
+		rawSettings      string
+		changedContainer string
+		expectedMutation bool
+		expectedWarning  string
+	}{
+		{"defaults are not applied", "test_data/pod_without_resources.json", `{"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"}, "unchangedContainers": "warn"}`, "", false, "spec.containers[0].resources (container 'pause'): container does not define all the required resources and mutation is disabled for the containers not changed by the request"},
+		{"limits are not clamped", "test_data/pod_exceeding_range.json", `{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m", "onExceed": "clamp"}, "unchangedContainers": "warn"}`, "", false, "spec.containers[0].resources.limits.cpu (container 'pause'): cpu limit '3m' exceeds the max allowed value '1m'"},
+		{"changed containers are mutated", "test_data/pod_without_resources.json", `{"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"}, "unchangedContainers": "warn"}`, "mycontainer", true, "(container 'pause')"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validateTestRequest(t, test.requestFile, test.rawSettings, updateRequest(t, test.changedContainer))
+			if !response.Accepted {
+				t.Fatalf("the request should be accepted: %v", *response.Message)
+			}
+			if (response.MutatedObject != nil) != test.expectedMutation {
+				t.Fatalf("expected mutation to be %t, got mutated object %v", test.expectedMutation, response.MutatedObject)
+			}
+			if len(response.Warnings) == 0 || !strings.Contains(response.Warnings[0], test.expectedWarning) {
+				t.Errorf("expected a warning containing '%s', got %v", test.expectedWarning, response.Warnings)
+			}
+			if !test.expectedMutation {
+				return
+			}
+			containers := response.MutatedObject.(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+			if _, found := containers[0].(map[string]interface{})["resources"]; found {
+				t.Errorf("the unchanged container was mutated: %v", containers[0])
+			}
+			if _, found := containers[1].(map[string]interface{})["resources"]; !found {
+				t.Errorf("the changed container was not mutated: %v", containers[1])
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/kubewarden/container-resources-policy/resource"
@@ -11,8 +13,60 @@ import (
 	api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
 	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+	"gopkg.in/inf.v0"
 )
 
+// fieldError is an error found in a field of a container. The field path is
+// relative to the container definition
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e fieldError) Error() string {
+	return e.err.Error()
+}
+
+func (e fieldError) Unwrap() error {
+	return e.err
+}
+
+// resourceField returns the path of the given resource in the limits or the
+// requests of a container
+func resourceField(section, resourceName string) string {
+	if strings.ContainsAny(resourceName, "./") {
+		return fmt.Sprintf("resources.%s[%s]", section, resourceName)
+	}
+	return fmt.Sprintf("resources.%s.%s", section, resourceName)
+}
+
+func limitError(resourceName string, err error) error {
+	return fieldError{field: resourceField("limits", resourceName), err: err}
+}
+
+func requestError(resourceName string, err error) error {
+	return fieldError{field: resourceField("requests", resourceName), err: err}
+}
+
+// flattenErrors returns the list of errors joined by errors.Join. Field errors
+// are not unwrapped
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(fieldError); ok {
+		return []error{err}
+	}
+	if joinedErrors, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := []error{}
+		for _, e := range joinedErrors.Unwrap() {
+			errs = append(errs, flattenErrors(e)...)
+		}
+		return errs
+	}
+	return []error{err}
+}
+
 func missingResourceQuantity(resources map[string]*api_resource.Quantity, resourceName string) bool {
 	resourceStr, found := resources[resourceName]
 	return !found || resourceStr == nil || len(strings.TrimSpace(string(*resourceStr))) == 0
@@ -20,8 +74,17 @@ func missingResourceQuantity(resources map[string]*api_resource.Quantity, resour
 
 func adjustResourceRequest(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration) bool {
 	if missingResourceQuantity(container.Resources.Requests, resourceName) {
-		if !resourceConfig.DefaultRequest.IsZero() {
-			newRequest := api_resource.Quantity(resourceConfig.DefaultRequest.String())
+		if isOvercommitForbidden(resourceName) && !missingResourceQuantity(container.Resources.Limits, resourceName) {
+			// Kubernetes requires the request to be equal to the limit
+			newRequest := *container.Resources.Limits[resourceName]
+			container.Resources.Requests[resourceName] = &newRequest
+			return true
+		}
+		if resourceConfig.skipDefaults {
+			return false
+		}
+		if request := derivedRequest(container, resourceName, resourceConfig); !request.IsZero() {
+			newRequest := api_resource.Quantity(request.String())
 			container.Resources.Requests[resourceName] = &newRequest
 			return true
 		}
@@ -30,66 +93,73 @@ func adjustResourceRequest(container *corev1.Container, resourceName string, res
 }
 
 func validateContainerResourceLimits(container *corev1.Container, settings *Settings) error {
-	if container.Resources.Limits == nil && settings.shouldIgnoreCpuValues() && settings.shouldIgnoreMemoryValues() {
-		return fmt.Errorf("container does not have any resource limits")
+	if container.Resources.Limits == nil && settings.shouldIgnoreAllValues() && !settings.forbidsLimits() {
+		return fieldError{field: "resources.limits", err: newRuleError(RuleRequiredLimit, "", "", "", "container does not have any resource limits")}
 	}
 
-	if settings.shouldIgnoreCpuValues() && missingResourceQuantity(container.Resources.Limits, "cpu") {
-		return fmt.Errorf("container does not have a cpu limit")
-	}
-
-	if settings.shouldIgnoreMemoryValues() && missingResourceQuantity(container.Resources.Limits, "memory") {
-		return fmt.Errorf("container does not have a memory limit")
+	errs := []error{}
+	for _, resourceName := range settings.resourceNames() {
+		if settings.resourceConfiguration(resourceName).limitsForbidden() {
+			continue
+		}
+		if settings.shouldIgnoreValues(resourceName) && missingResourceQuantity(container.Resources.Limits, resourceName) {
+			errs = append(errs, limitError(resourceName, newRuleError(RuleRequiredLimit, resourceName, "", "", "container does not have a %s limit", resourceName)))
+		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func validateContainerResourceRequests(container *corev1.Container, settings *Settings) error {
-	if container.Resources.Requests == nil && settings.shouldIgnoreCpuValues() && settings.shouldIgnoreMemoryValues() {
-		return fmt.Errorf("container does not have any resource requests")
+	if container.Resources.Requests == nil && settings.shouldIgnoreAllValues() {
+		return fieldError{field: "resources.requests", err: newRuleError(RuleRequiredRequest, "", "", "", "container does not have any resource requests")}
 	}
 
-	_, found := container.Resources.Requests["cpu"]
-	if !found && settings.shouldIgnoreCpuValues() {
-		return fmt.Errorf("container does not have a cpu request")
-	}
-
-	_, found = container.Resources.Requests["memory"]
-	if !found && settings.shouldIgnoreMemoryValues() {
-		return fmt.Errorf("container does not have a memory request")
+	errs := []error{}
+	for _, resourceName := range settings.resourceNames() {
+		_, found := container.Resources.Requests[resourceName]
+		if !found && settings.shouldIgnoreValues(resourceName) {
+			errs = append(errs, requestError(resourceName, newRuleError(RuleRequiredRequest, resourceName, "", "", "container does not have a %s request", resourceName)))
+		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // If IgnoreValues is set to true, confirm that the respective limits/requests are set.
 // We only check for the presence of the limits/requests, not their values.
 // Returns an error if the limits/requests are not set and IgnoreValues is set to true.
+// All the missing limits/requests are reported.
 func validateContainerResources(container *corev1.Container, settings *Settings) error {
-	if container.Resources == nil && (settings.shouldIgnoreCpuValues() || settings.shouldIgnoreMemoryValues()) {
-		missing := fmt.Sprintf("required Cpu:%t, Memory:%t", settings.shouldIgnoreCpuValues(), settings.shouldIgnoreMemoryValues())
-		return fmt.Errorf("container does not have any resource limits or requests: %s", missing)
-	}
-	if err := validateContainerResourceLimits(container, settings); err != nil {
-		return err
-	}
-	if err := validateContainerResourceRequests(container, settings); err != nil {
-		return err
+	if container.Resources == nil {
+		required := []string{}
+		for _, resourceName := range settings.resourceNames() {
+			if settings.shouldIgnoreValues(resourceName) {
+				required = append(required, resourceName)
+			}
+		}
+		if len(required) > 0 {
+			return fieldError{field: "resources", err: newRuleError(RuleRequiredResources, "", "", "", "container does not have any resource limits or requests: required %s", strings.Join(required, ", "))}
+		}
+		return nil
 	}
-	return nil
+	return errors.Join(
+		validateContainerResourceLimits(container, settings),
+		validateContainerResourceRequests(container, settings),
+	)
 }
 
-// When the CPU/Memory request is specified: no action or check is done against it.
-// When the CPU/Memory request is not specified: the policy mutates the container definition, the `defaultRequest` value is used. The policy does not check the consistency of the applied value.
+// When the request of a resource is specified: it is validated by validateAndAdjustContainerResourceLimits.
+// When the request of a resource is not specified: the policy mutates the container definition, the value computed by the request derivation strategy is used. It is the `defaultRequest` value by default.
 // Return `true` when the container has been mutated
 func validateAndAdjustContainerResourceRequests(container *corev1.Container, settings *Settings) bool {
 	mutated := false
-	if settings.Memory != nil {
-		mutated = adjustResourceRequest(container, "memory", settings.Memory)
-	}
-	if settings.Cpu != nil {
-		mutated = adjustResourceRequest(container, "cpu", settings.Cpu) || mutated
+	for _, resourceName := range settings.resourceNames() {
+		// Missing requests of these resources are reported by validateContainerResources
+		if settings.shouldIgnoreValues(resourceName) {
+			continue
+		}
+		mutated = adjustResourceRequest(container, resourceName, settings.resourceConfiguration(resourceName)) || mutated
 	}
 	return mutated
 }
@@ -100,69 +170,253 @@ func isResourceLimitGreaterThanRequest(container *corev1.Container, resourceName
 		resourceStr := container.Resources.Limits[resourceName]
 		resourceLimit, err := resource.ParseQuantity(string(*resourceStr))
 		if err != nil {
-			return errors.Join(fmt.Errorf("invalid %s limit", resourceName), err)
+			return errors.Join(invalidQuantityError(resourceName, string(*resourceStr), "invalid %s limit", resourceName), err)
 		}
 		resourceStr = container.Resources.Requests[resourceName]
 		resourceRequest, err := resource.ParseQuantity(string(*resourceStr))
 		if err != nil {
-			return errors.Join(fmt.Errorf("invalid %s request", resourceName), err)
+			return errors.Join(invalidQuantityError(resourceName, string(*resourceStr), "invalid %s request", resourceName), err)
 		}
 		if resourceLimit.Cmp(resourceRequest) < 0 {
-			return fmt.Errorf("%s limit '%s' is less than the requested '%s' value. Please, change the resource configuration or change the policy settings to accommodate the requested value.", resourceName, resourceLimit.String(), resourceRequest.String())
+			return newRuleError(RuleLimitGreaterThanRequest, resourceName, resourceLimit.String(), resourceRequest.String(), "%s limit '%s' is less than the requested '%s' value. Please, change the resource configuration or change the policy settings to accommodate the requested value.", resourceName, resourceLimit.String(), resourceRequest.String())
 		}
 	}
 	return nil
 }
 
+// validateResourceRequest validates the request defined by the user against
+// the passed resourceConfig. Missing requests are not validated, they are
+// handled by the mutation. The max request is only enforced when it is defined.
+func validateResourceRequest(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration) error {
+	if missingResourceQuantity(container.Resources.Requests, resourceName) {
+		return nil
+	}
+	resourceStr := container.Resources.Requests[resourceName]
+	resourceRequest, err := resource.ParseQuantity(string(*resourceStr))
+	if err != nil {
+		return invalidQuantityError(resourceName, string(*resourceStr), "invalid %s request", resourceName)
+	}
+	if resourceRequest.Cmp(resourceConfig.MinRequest) < 0 {
+		return newRuleError(RuleMinRequest, resourceName, resourceRequest.String(), resourceConfig.MinRequest.String(), "%s request '%s' is less than the min allowed value '%s'", resourceName, resourceRequest.String(), resourceConfig.MinRequest.String())
+	}
+	if !resourceConfig.MaxRequest.IsZero() && resourceRequest.Cmp(resourceConfig.MaxRequest) > 0 {
+		return newRuleError(RuleMaxRequest, resourceName, resourceRequest.String(), resourceConfig.MaxRequest.String(), "%s request '%s' exceeds the max allowed request value '%s'", resourceName, resourceRequest.String(), resourceConfig.MaxRequest.String())
+	}
+	return nil
+}
+
+// cmpLimitRequestRatio compares the limit/request ratio with the given ratio
+// using exact arithmetic. It returns -1, 0 or 1 like resource.Quantity.Cmp.
+// A zero request is handled as an infinite ratio.
+func cmpLimitRequestRatio(limit, request, ratio resource.Quantity) int {
+	product := new(inf.Dec).Mul(request.AsDec(), ratio.AsDec())
+	return limit.AsDec().Cmp(product)
+}
+
+// limitRequestRatio returns the limit/request ratio, rounded to three decimal
+// places. It is empty when the request is zero
+func limitRequestRatio(limit, request resource.Quantity) string {
+	if request.Sign() == 0 {
+		return ""
+	}
+	ratio := new(inf.Dec).QuoRound(limit.AsDec(), request.AsDec(), 3, inf.RoundHalfUp)
+	return strings.TrimSuffix(strings.TrimRight(ratio.String(), "0"), ".")
+}
+
+// validateLimitRequestRatio validates the limit to request ratio of the
+// container against the ratio bounds defined in the passed resourceConfig.
+// The ratio is only validated when both limit and request are defined.
+func validateLimitRequestRatio(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration) error {
+	if resourceConfig.MaxLimitRequestRatio.IsZero() && resourceConfig.MinLimitRequestRatio.IsZero() {
+		return nil
+	}
+	if missingResourceQuantity(container.Resources.Requests, resourceName) || missingResourceQuantity(container.Resources.Limits, resourceName) {
+		return nil
+	}
+	resourceLimit, err := resource.ParseQuantity(string(*container.Resources.Limits[resourceName]))
+	if err != nil {
+		return invalidQuantityError(resourceName, string(*container.Resources.Limits[resourceName]), "invalid %s limit", resourceName)
+	}
+	resourceRequest, err := resource.ParseQuantity(string(*container.Resources.Requests[resourceName]))
+	if err != nil {
+		return invalidQuantityError(resourceName, string(*container.Resources.Requests[resourceName]), "invalid %s request", resourceName)
+	}
+	if !resourceConfig.MaxLimitRequestRatio.IsZero() && cmpLimitRequestRatio(resourceLimit, resourceRequest, resourceConfig.MaxLimitRequestRatio) > 0 {
+		return newRuleError(RuleMaxLimitRequestRatio, resourceName, limitRequestRatio(resourceLimit, resourceRequest), resourceConfig.MaxLimitRequestRatio.String(), "%s limit '%s' to request '%s' ratio exceeds the max allowed ratio '%s'", resourceName, resourceLimit.String(), resourceRequest.String(), resourceConfig.MaxLimitRequestRatio.String())
+	}
+	if !resourceConfig.MinLimitRequestRatio.IsZero() && cmpLimitRequestRatio(resourceLimit, resourceRequest, resourceConfig.MinLimitRequestRatio) < 0 {
+		return newRuleError(RuleMinLimitRequestRatio, resourceName, limitRequestRatio(resourceLimit, resourceRequest), resourceConfig.MinLimitRequestRatio.String(), "%s limit '%s' to request '%s' ratio is less than the min allowed ratio '%s'", resourceName, resourceLimit.String(), resourceRequest.String(), resourceConfig.MinLimitRequestRatio.String())
+	}
+	return nil
+}
+
+// validateResourceLimit validates the limit defined by the user against the
+// passed resourceConfig.
+func validateResourceLimit(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration) error {
+	resourceStr := container.Resources.Limits[resourceName]
+	resourceLimit, err := resource.ParseQuantity(string(*resourceStr))
+	if err != nil {
+		return invalidQuantityError(resourceName, string(*resourceStr), "invalid %s limit", resourceName)
+	}
+	if resourceConfig.limitsBounded() && resourceLimit.Cmp(resourceConfig.MaxLimit) > 0 {
+		return newRuleError(RuleMaxLimit, resourceName, resourceLimit.String(), resourceConfig.MaxLimit.String(), "%s limit '%s' exceeds the max allowed value '%s'", resourceName, resourceLimit.String(), resourceConfig.MaxLimit.String())
+	}
+	if resourceLimit.Cmp(resourceConfig.MinLimit) < 0 {
+		return newRuleError(RuleMinLimit, resourceName, resourceLimit.String(), resourceConfig.MinLimit.String(), "%s limit '%s' is less than the min allowed value '%s'", resourceName, resourceLimit.String(), resourceConfig.MinLimit.String())
+	}
+	return nil
+}
+
+// clampResourceLimit lowers the limit of the container to the max limit when
+// it exceeds it. The request is lowered to the new limit as well, when it is
+// greater than it. Returns true when it mutates the container.
+func clampResourceLimit(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration) (bool, error) {
+	resourceLimit, err := resource.ParseQuantity(string(*container.Resources.Limits[resourceName]))
+	if err != nil {
+		return false, invalidQuantityError(resourceName, string(*container.Resources.Limits[resourceName]), "invalid %s limit", resourceName)
+	}
+	if !resourceConfig.limitsBounded() || resourceLimit.Cmp(resourceConfig.MaxLimit) <= 0 {
+		return false, nil
+	}
+	newLimit := api_resource.Quantity(resourceConfig.MaxLimit.String())
+	container.Resources.Limits[resourceName] = &newLimit
+	if !missingResourceQuantity(container.Resources.Requests, resourceName) {
+		resourceRequest, err := resource.ParseQuantity(string(*container.Resources.Requests[resourceName]))
+		if err != nil {
+			return false, invalidQuantityError(resourceName, string(*container.Resources.Requests[resourceName]), "invalid %s request", resourceName)
+		}
+		if resourceRequest.Cmp(resourceConfig.MaxLimit) > 0 {
+			newRequest := newLimit
+			container.Resources.Requests[resourceName] = &newRequest
+		}
+	}
+	return true, nil
+}
+
 // validateAndAdjustContainerResourceLimit validates the container against the passed resourceConfig // and mutates it if the validation didn't pass.
+// The request defined by the user is validated as well, before any mutation.
+// Limits exceeding the max limit are clamped when configured.
 // Returns true when it mutates the container.
 func validateAndAdjustContainerResourceLimit(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration) (bool, error) {
+	var requestErr error
+	if err := validateResourceRequest(container, resourceName, resourceConfig); err != nil {
+		requestErr = requestError(resourceName, err)
+	}
 	if missingResourceQuantity(container.Resources.Limits, resourceName) {
-		if !resourceConfig.DefaultLimit.IsZero() {
+		if requestErr != nil {
+			return false, requestErr
+		}
+		if isOvercommitForbidden(resourceName) && !missingResourceQuantity(container.Resources.Requests, resourceName) {
+			// Kubernetes requires the limit to be equal to the request
+			newLimit := *container.Resources.Requests[resourceName]
+			container.Resources.Limits[resourceName] = &newLimit
+			if err := validateResourceLimit(container, resourceName, resourceConfig); err != nil {
+				return false, limitError(resourceName, err)
+			}
+			return true, nil
+		}
+		if limit, found := derivedLimit(container, resourceName, resourceConfig); found && !resourceConfig.skipDefaults {
+			newLimit := api_resource.Quantity(limit.String())
+			container.Resources.Limits[resourceName] = &newLimit
+			if resourceConfig.OnExceed == OnExceedClamp {
+				if _, err := clampResourceLimit(container, resourceName, resourceConfig); err != nil {
+					return false, limitError(resourceName, err)
+				}
+			}
+			if err := validateResourceLimit(container, resourceName, resourceConfig); err != nil {
+				return false, limitError(resourceName, err)
+			}
+			return true, nil
+		}
+		if !resourceConfig.DefaultLimit.IsZero() && !resourceConfig.skipDefaults {
 			newLimit := api_resource.Quantity(resourceConfig.DefaultLimit.String())
 			container.Resources.Limits[resourceName] = &newLimit
 			return true, nil
 		}
 	} else {
-		resourceStr := container.Resources.Limits[resourceName]
-		resourceLimit, err := resource.ParseQuantity(string(*resourceStr))
-		if err != nil {
-			return false, fmt.Errorf("invalid %s limit", resourceName)
+		if resourceConfig.OnExceed == OnExceedClamp && requestErr == nil {
+			clamped, err := clampResourceLimit(container, resourceName, resourceConfig)
+			if err != nil {
+				return false, limitError(resourceName, err)
+			}
+			if clamped {
+				return true, nil
+			}
 		}
-		if resourceLimit.Cmp(resourceConfig.MaxLimit) > 0 {
-			return false, fmt.Errorf("%s limit '%s' exceeds the max allowed value '%s'", resourceName, resourceLimit.String(), resourceConfig.MaxLimit.String())
+		if err := validateResourceLimit(container, resourceName, resourceConfig); err != nil {
+			return false, errors.Join(requestErr, limitError(resourceName, err))
+		}
+	}
+	return false, requestErr
+}
+
+// validateAndRemoveResourceLimit handles a resource whose limits are not
+// allowed: the limit defined by the container is rejected or removed,
+// according to the limit mode. The request defined by the user is validated,
+// unless only its presence is checked.
+// Returns true when it mutates the container.
+func validateAndRemoveResourceLimit(container *corev1.Container, resourceName string, resourceConfig *ResourceConfiguration, ignoreValues bool) (bool, error) {
+	var requestErr error
+	if !ignoreValues {
+		if err := validateResourceRequest(container, resourceName, resourceConfig); err != nil {
+			requestErr = requestError(resourceName, err)
 		}
 	}
-	return false, nil
+	if _, found := container.Resources.Limits[resourceName]; !found {
+		return false, requestErr
+	}
+	if resourceConfig.LimitMode == LimitModeForbid {
+		return false, errors.Join(requestErr, limitError(resourceName, newRuleError(RuleForbiddenLimit, resourceName, string(*container.Resources.Limits[resourceName]), "", "%s limits are not allowed", resourceName)))
+	}
+	if requestErr != nil {
+		return false, requestErr
+	}
+	delete(container.Resources.Limits, resourceName)
+	return true, nil
 }
 
 // validateAndAdjustContainerResourceLimits validates the container and mutates
 // it when possible, when it doesn't pass validation.
 //
-// When the CPU/Memory limit is specified: the request is accepted if the limit
-// defined by the container is less than or equal to the `maxLimit`, or
-// IgnoreValues is true. Otherwise the request is rejected.
+// When the limit of a resource is specified: the request is accepted if the limit
+// defined by the container is between the `minLimit` and the `maxLimit`, or
+// IgnoreValues is true. Otherwise the request is rejected. The same happens
+// when the request of a resource is less than the `minRequest` or greater than the
+// `maxRequest`. Requests are checked before any mutation.
 //
-// When the CPU/Memory limit is not specified: the container is mutated to use
+// When the limit of a resource is not specified: the container is mutated to use
 // the `defaultLimit`.
 //
+// When the limits of a resource are not allowed: the containers defining the
+// limit are rejected, or the limit is removed.
+//
+// All the resources are verified, even when an error is found.
+//
 // Return `true` when the container has been mutated.
 func validateAndAdjustContainerResourceLimits(container *corev1.Container, settings *Settings) (bool, error) {
 	mutated := false
-	if !settings.shouldIgnoreMemoryValues() && settings.Memory != nil {
-		var err error
-		mutated, err = validateAndAdjustContainerResourceLimit(container, "memory", settings.Memory)
-		if err != nil {
-			return false, err
+	errs := []error{}
+	for _, resourceName := range settings.resourceNames() {
+		resourceConfig := settings.resourceConfiguration(resourceName)
+		if resourceConfig.limitsForbidden() {
+			resourceMutation, err := validateAndRemoveResourceLimit(container, resourceName, resourceConfig, settings.shouldIgnoreValues(resourceName))
+			if err != nil {
+				errs = append(errs, err)
+			}
+			mutated = mutated || resourceMutation
+			continue
 		}
-	}
-
-	if !settings.shouldIgnoreCpuValues() && settings.Cpu != nil {
-		cpuMutation, err := validateAndAdjustContainerResourceLimit(container, "cpu", settings.Cpu)
+		if settings.shouldIgnoreValues(resourceName) {
+			continue
+		}
+		resourceMutation, err := validateAndAdjustContainerResourceLimit(container, resourceName, resourceConfig)
 		if err != nil {
-			return false, err
+			errs = append(errs, err)
 		}
-		mutated = mutated || cpuMutation
+		mutated = mutated || resourceMutation
+	}
+	if len(errs) > 0 {
+		return false, errors.Join(errs...)
 	}
 	return mutated, nil
 }
@@ -180,63 +434,366 @@ func validateAndAdjustContainer(container *corev1.Container, settings *Settings)
 	if container.Resources.Requests == nil {
 		container.Resources.Requests = make(map[string]*api_resource.Quantity)
 	}
+	// The limits defined by the user are reported by the ratio errors of the
+	// clamped limits
+	original := &corev1.Container{Resources: &corev1.ResourceRequirements{Limits: maps.Clone(container.Resources.Limits)}}
 	limitsMutation, err := validateAndAdjustContainerResourceLimits(container, settings)
 	if err != nil {
+		// The remaining checks verify the values applied by the mutation.
+		// There is no point in mutating the container when it is invalid
 		return false, err
 	}
+	errs := []error{}
 	requestsMutation := validateAndAdjustContainerResourceRequests(container, settings)
 	if limitsMutation || requestsMutation {
 		// If the container has been mutated, we need to check that the limit is greater than the request
-		// for both CPU and Memory. If the limit is less than the request, we reject the request.
+		// for all the resources. If the limit is less than the request, we reject the request.
 		// Because the user need to adjust the resource or change the policy configuration. Otherwise,
 		// Kubernetes will not accept the resource mutated by the policy.
 		errorMsg := "There is an issue after resource limits mutation"
 		if requestsMutation {
 			errorMsg = "There is an issue after resource requests mutation"
 		}
-		if err := isResourceLimitGreaterThanRequest(container, "memory"); err != nil {
-			return false, errors.Join(errors.New(errorMsg), err)
+		for _, resourceName := range settings.resourceNames() {
+			if err := isResourceLimitGreaterThanRequest(container, resourceName); err != nil {
+				errs = append(errs, limitError(resourceName, fmt.Errorf("%s: %w", errorMsg, err)))
+			}
 		}
-		if err := isResourceLimitGreaterThanRequest(container, "cpu"); err != nil {
-			return false, errors.Join(errors.New(errorMsg), err)
+	}
+	if requestsMutation {
+		// The derived requests can be out of the configured bounds
+		errs = append(errs, validateAdjustedRequests(container, settings)...)
+	}
+	// The ratios are validated after the defaults are applied
+	clamped := clampedLimits(original, container, settings)
+	for _, resourceName := range settings.resourceNames() {
+		if settings.shouldIgnoreValues(resourceName) {
+			continue
+		}
+		err := validateLimitRequestRatio(container, resourceName, settings.resourceConfiguration(resourceName))
+		if err != nil && slices.Contains(clamped, resourceName) {
+			err = fmt.Errorf("%s limit '%s' has been clamped to '%s': %w", resourceName, *original.Resources.Limits[resourceName], *container.Resources.Limits[resourceName], err)
+		}
+		if err != nil {
+			errs = append(errs, limitError(resourceName, err))
 		}
 	}
+	if len(errs) > 0 {
+		return false, errors.Join(errs...)
+	}
 	return limitsMutation || requestsMutation, nil
 }
 
+// validateAdjustedRequests validates the requests applied by a mutation
+// against the configured bounds
+func validateAdjustedRequests(container *corev1.Container, settings *Settings) []error {
+	errs := []error{}
+	for _, resourceName := range settings.resourceNames() {
+		if settings.shouldIgnoreValues(resourceName) {
+			continue
+		}
+		if err := validateResourceRequest(container, resourceName, settings.resourceConfiguration(resourceName)); err != nil {
+			errs = append(errs, requestError(resourceName, fmt.Errorf("There is an issue after resource requests mutation: %w", err)))
+		}
+	}
+	return errs
+}
+
 func shouldSkipContainer(image string, ignoreImages []string) bool {
-	for _, ignoreImageUri := range ignoreImages {
-		if !strings.HasSuffix(ignoreImageUri, "*") {
-			if image == ignoreImageUri {
-				return true
-			}
-		} else {
-			imageUriNoSuffix := strings.TrimSuffix(ignoreImageUri, "*")
-			if strings.HasPrefix(image, imageUriNoSuffix) {
-				return true
-			}
+	return imageMatchesAny(image, ignoreImages)
+}
+
+// isContainerSkipped returns true when the container is not checked: its
+// kind is skipped, its image is ignored or it is exempted
+func isContainerSkipped(container *corev1.Container, action string, settings *Settings) bool {
+	return action == ContainerActionSkip || shouldSkipContainer(container.Image, settings.IgnoreImages) ||
+		slices.Contains(settings.exemptContainers, containerName(container.Name)) ||
+		(settings.unchangedContainersAction() == UnchangedContainersSkip && settings.isContainerUnchanged(container))
+}
+
+// clampedLimits returns the names of the resources whose limit, defined by
+// the user, has been clamped by the validation pipeline. The clamp is the
+// only mutation changing the values defined by the user
+func clampedLimits(original, adjusted *corev1.Container, settings *Settings) []string {
+	names := []string{}
+	if original.Resources == nil {
+		return names
+	}
+	for _, resourceName := range settings.resourceNames() {
+		if missingResourceQuantity(original.Resources.Limits, resourceName) || missingResourceQuantity(adjusted.Resources.Limits, resourceName) {
+			continue
+		}
+		if *original.Resources.Limits[resourceName] != *adjusted.Resources.Limits[resourceName] {
+			names = append(names, resourceName)
 		}
 	}
-	return false
+	return names
 }
 
-func validatePodSpec(pod *corev1.PodSpec, settings *Settings) (bool, error) {
-	mutated := false
-	for _, container := range pod.Containers {
-		if shouldSkipContainer(container.Image, settings.IgnoreImages) {
+// removedLimits returns the names of the resources whose limit, defined by
+// the user, has been removed by the validation pipeline
+func removedLimits(original, adjusted *corev1.Container) []string {
+	names := []string{}
+	if original.Resources == nil {
+		return names
+	}
+	for resourceName := range original.Resources.Limits {
+		if _, found := adjusted.Resources.Limits[resourceName]; !found {
+			names = append(names, resourceName)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// mutationWarnings returns the warnings describing the limits, and the
+// requests, clamped or removed by the validation pipeline
+func mutationWarnings(original, adjusted *corev1.Container, clamped, removed []string) []error {
+	warnings := []error{}
+	for _, resourceName := range clamped {
+		originalLimit, newLimit := *original.Resources.Limits[resourceName], *adjusted.Resources.Limits[resourceName]
+		warnings = append(warnings, limitError(resourceName, fmt.Errorf("%s limit '%s' exceeds the max allowed value and has been lowered to '%s'", resourceName, originalLimit, newLimit)))
+		if missingResourceQuantity(original.Resources.Requests, resourceName) {
 			continue
 		}
-		if err := validateContainerResources(container, settings); err != nil {
-			return false, err
+		if originalRequest, newRequest := *original.Resources.Requests[resourceName], *adjusted.Resources.Requests[resourceName]; originalRequest != newRequest {
+			warnings = append(warnings, requestError(resourceName, fmt.Errorf("%s request '%s' has been lowered to the new limit '%s'", resourceName, originalRequest, newRequest)))
 		}
+	}
+	for _, resourceName := range removed {
+		warnings = append(warnings, limitError(resourceName, fmt.Errorf("%s limits are not allowed. The limit has been removed", resourceName)))
+	}
+	return warnings
+}
 
-		containerMutated, err := validateAndAdjustContainer(container, settings)
-		if err != nil {
-			return false, err
+// definesAllResources returns true when the validation pipeline did not add
+// any limit or request to the container
+func definesAllResources(original, adjusted *corev1.Container) bool {
+	var limits, requests map[string]*api_resource.Quantity
+	if original.Resources != nil {
+		limits, requests = original.Resources.Limits, original.Resources.Requests
+	}
+	for resourceName := range adjusted.Resources.Limits {
+		if _, found := limits[resourceName]; !found {
+			return false
+		}
+	}
+	for resourceName := range adjusted.Resources.Requests {
+		if _, found := requests[resourceName]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+// validateContainer runs the validation and mutation pipeline on the container
+// according to the action configured for its kind.
+// Returns true when it mutates the container, and the warnings describing the
+// clamped and removed values.
+func validateContainer(container *corev1.Container, action string, settings *Settings) (bool, []error, error) {
+	if isContainerSkipped(container, action, settings) {
+		return false, nil, nil
+	}
+	resourcesErr := validateContainerResources(container, settings)
+	// Run the pipeline on a copy of the container. The container is changed
+	// only when it is valid, so invalid containers are never partially
+	// mutated
+	containerCopy := *container
+	if container.Resources != nil {
+		containerCopy.Resources = &corev1.ResourceRequirements{
+			Limits:   maps.Clone(container.Resources.Limits),
+			Requests: maps.Clone(container.Resources.Requests),
+		}
+	}
+	mutated, err := validateAndAdjustContainer(&containerCopy, settings)
+	if err != nil {
+		return false, nil, errors.Join(resourcesErr, err)
+	}
+	clamped, removed := []string{}, []string{}
+	if mutated {
+		clamped, removed = clampedLimits(container, &containerCopy, settings), removedLimits(container, &containerCopy)
+	}
+	if mutated && (action == ContainerActionValidate || settings.DisableMutation) {
+		errs := []error{resourcesErr}
+		// The clamped and removed limits are reported as they would be
+		// without the mutation
+		for _, resourceName := range clamped {
+			errs = append(errs, limitError(resourceName, newRuleError(RuleMaxLimit, resourceName, string(*container.Resources.Limits[resourceName]), string(*containerCopy.Resources.Limits[resourceName]), "%s limit '%s' exceeds the max allowed value '%s'", resourceName, *container.Resources.Limits[resourceName], *containerCopy.Resources.Limits[resourceName])))
 		}
+		for _, resourceName := range removed {
+			errs = append(errs, limitError(resourceName, newRuleError(RuleForbiddenLimit, resourceName, string(*container.Resources.Limits[resourceName]), "", "%s limits are not allowed", resourceName)))
+		}
+		if !definesAllResources(container, &containerCopy) {
+			reason := "mutation is disabled"
+			if action == ContainerActionValidate {
+				reason = "mutation is disabled for this kind of container"
+			} else if settings.isContainerWarnedOnly(container) {
+				reason = "mutation is disabled for the containers not changed by the request"
+			}
+			errs = append(errs, fieldError{field: "resources", err: newRuleError(RuleMutationDisabled, "", "", "", "container does not define all the required resources and %s", reason)})
+		}
+		return false, nil, errors.Join(errs...)
+	}
+	if resourcesErr != nil {
+		return false, nil, resourcesErr
+	}
+	warnings := mutationWarnings(container, &containerCopy, clamped, removed)
+	if mutated {
+		container.Resources = containerCopy.Resources
+	}
+	return mutated, warnings, nil
+}
+
+func containerName(name *string) string {
+	if name == nil {
+		return ""
+	}
+	return *name
+}
+
+// isSidecarContainer returns true when the init container is a sidecar
+// container. Which is an init container with the restartPolicy set to Always
+func isSidecarContainer(container *corev1.Container) bool {
+	return container.RestartPolicy == "Always"
+}
+
+// initContainerAction returns the action configured for the init container,
+// and the description of its kind
+func initContainerAction(container *corev1.Container, settings *Settings) (string, string) {
+	if isSidecarContainer(container) {
+		return settings.sidecarContainersAction(), "sidecar container"
+	}
+	return settings.initContainersAction(), "init container"
+}
+
+// containerViolations returns the errors found in a container. Each error
+// includes the path of the invalid field and the container name
+func containerViolations(err error, containerPath, kind string, name *string) []error {
+	violations := []error{}
+	for _, e := range flattenErrors(err) {
+		path := containerPath
+		if fe, ok := e.(fieldError); ok {
+			path = fmt.Sprintf("%s.%s", containerPath, fe.field)
+		}
+		violations = append(violations, violationError{field: path, container: containerName(name), containerKind: kind, err: e})
+	}
+	return violations
+}
+
+// podSpecPath returns the path of the PodSpec in the given kind of object
+func podSpecPath(kind string) string {
+	switch kind {
+	case "Pod":
+		return "spec"
+	case "CronJob":
+		return "spec.jobTemplate.spec.template.spec"
+	default:
+		return "spec.template.spec"
+	}
+}
+
+// containerSettings returns the settings used for the given container. The
+// image profile matching the container image, and then the container profile
+// matching the container name, are merged over the pod settings. The pod
+// settings are used when no profile matches.
+// When configured, the default values of the resources defined by the pod
+// level resources are not applied.
+func containerSettings(container *corev1.Container, podResources *corev1.ResourceRequirements, settings *Settings) *Settings {
+	imageSettings := settings.forImage(container.Image).forContainerName(containerName(container.Name))
+	if settings.Pod != nil && settings.Pod.SkipContainerDefaults && podResources != nil {
+		return imageSettings.withoutContainerDefaults(podResourceNames(podResources))
+	}
+	return imageSettings
+}
+
+// validatePodContainer validates and mutates a container of a pod, using the
+// settings selected for it. It returns the warnings and the violations found,
+// each one including the path of the invalid field and the container name.
+func validatePodContainer(container *corev1.Container, action, containerPath, kind string, podResources *corev1.ResourceRequirements, settings *Settings) (bool, []error, []error) {
+	adjustedSettings := containerSettings(container, podResources, settings)
+	warnedOnly := settings.isContainerWarnedOnly(container)
+	if warnedOnly {
+		// The mutations the container would require are reported as
+		// violations, and then returned as warnings
+		mutationDisabled := *adjustedSettings
+		mutationDisabled.DisableMutation = true
+		adjustedSettings = &mutationDisabled
+	}
+	mutated, warnings, err := validateContainer(container, action, adjustedSettings)
+	violations := []error{}
+	// The violations of the containers not changed by an UPDATE request are
+	// returned as warnings when configured
+	if err != nil && warnedOnly {
+		warnings = append(warnings, err)
+	} else if err != nil {
+		violations = containerViolations(err, containerPath, kind, container.Name)
+	}
+	if !isContainerSkipped(container, action, adjustedSettings) {
+		growthWarnings, growthErr := validateContainerGrowth(container, adjustedSettings)
+		violations = append(violations, containerViolations(growthErr, containerPath, kind, container.Name)...)
+		warnings = append(warnings, growthWarnings...)
+	}
+	return mutated, containerViolations(errors.Join(warnings...), containerPath, kind, container.Name), violations
+}
+
+// validatePodSpec validates and mutates all the containers of the PodSpec.
+// The podResources are the pod level resources, nil when not defined.
+// All the violations found are returned, each one including the path of the
+// invalid field, starting from the passed podSpecPath. Only the valid
+// containers are mutated. Therefore, the returned mutation flag can be true
+// even when violations are found.
+func validatePodSpec(pod *corev1.PodSpec, podResources *corev1.ResourceRequirements, podSpecPath string, settings *Settings) (bool, []error, error) {
+	mutated := false
+	violations, warnings := []error{}, []error{}
+	checkContainer := func(container *corev1.Container, action, containerPath, kind string) bool {
+		containerMutated, containerWarnings, containerViolations := validatePodContainer(container, action, containerPath, kind, podResources, settings)
 		mutated = mutated || containerMutated
+		warnings = append(warnings, containerWarnings...)
+		violations = append(violations, containerViolations...)
+		return containerMutated
 	}
-	return mutated, nil
+	if !settings.ephemeralContainersOnly {
+		for i, container := range pod.Containers {
+			checkContainer(container, ContainerActionDefault, fmt.Sprintf("%s.containers[%d]", podSpecPath, i), "container")
+		}
+		for i, container := range pod.InitContainers {
+			action, kind := initContainerAction(container, settings)
+			checkContainer(container, action, fmt.Sprintf("%s.initContainers[%d]", podSpecPath, i), kind)
+		}
+	}
+	for i, ephemeralContainer := range pod.EphemeralContainers {
+		container := &corev1.Container{
+			Name:      ephemeralContainer.Name,
+			Image:     ephemeralContainer.Image,
+			Resources: ephemeralContainer.Resources,
+		}
+		if checkContainer(container, settings.ephemeralContainersAction(), fmt.Sprintf("%s.ephemeralContainers[%d]", podSpecPath, i), "ephemeral container") {
+			ephemeralContainer.Resources = container.Resources
+		}
+	}
+	if settings.ephemeralContainersOnly {
+		// The pod level values are not changed by the ephemeral containers
+		return false, warnings, errors.Join(violations...)
+	}
+	warnUnchanged := settings.unchangedContainersAction() == UnchangedContainersWarn
+	// The pod level checks are applied even when the containers are not
+	// valid, unless the containers define invalid quantities: they are already
+	// reported, and the pod totals cannot be computed
+	invalidQuantities := hasRuleViolation(violations, RuleInvalidQuantity)
+	if !invalidQuantities && !(settings.podUnchanged && settings.unchangedContainersAction() == UnchangedContainersSkip) {
+		// The QoS class and the pod totals include the values applied by
+		// the mutation
+		qosMutated, qosWarnings, qosViolations := validatePodQos(pod, podResources, podSpecPath, settings)
+		mutated = mutated || qosMutated
+		warnings = append(warnings, qosWarnings...)
+		podViolations := append(qosViolations, validatePodResources(pod, podResources, podSpecPath, settings.Pod)...)
+		if settings.podUnchanged && warnUnchanged {
+			warnings = append(warnings, podViolations...)
+		} else {
+			violations = append(violations, podViolations...)
+		}
+	}
+	return mutated, warnings, errors.Join(violations...)
 }
 
 func validate(payload []byte) ([]byte, error) {
@@ -257,19 +814,112 @@ func validate(payload []byte) ([]byte, error) {
 			kubewarden.Code(400))
 	}
 
-	podSpec, err := kubewarden.ExtractPodSpecFromObject(validationRequest)
-	if err == nil {
-		mutatePod, err := validatePodSpec(&podSpec, &settings)
+	if reason := settings.exemptionReason(&validationRequest.Request); reason != "" {
+		return acceptRequest(nil, map[string]string{exemptionAuditAnnotation: reason})
+	}
+
+	gvk := validationRequest.Request.Kind
+	if !settings.supportedKind(gvk) {
+		message := unsupportedKindError(gvk).Error()
+		switch settings.unsupportedKindsAction() {
+		case UnsupportedKindsAccept:
+			return acceptRequest(nil, map[string]string{unsupportedKindAuditAnnotation: message})
+		case UnsupportedKindsWarn:
+			return acceptRequest([]string{message}, map[string]string{unsupportedKindAuditAnnotation: message})
+		default:
+			return kubewarden.RejectRequest(kubewarden.Message(message), kubewarden.Code(400))
+		}
+	}
+
+	// The raw object keeps the fields unknown to the PodSpec type, like the
+	// pod level resources. They must be preserved by the mutation. The objects
+	// of the supported kinds which cannot be read are always rejected
+	object, err := newObject(validationRequest.Request.Object)
+	if err != nil {
+		return kubewarden.RejectRequest(kubewarden.Message(malformedObjectError(gvk, err).Error()), kubewarden.Code(400))
+	}
+	targets := settings.podTargets(gvk)
+	violations := []error{}
+	// An invalid or expired exemption is reported like the other violations,
+	// and the object is validated as usual
+	exemption, err := settings.objectExemption(object, targets)
+	if err != nil {
+		violations = append(violations, err)
+	}
+	auditAnnotations := map[string]string{}
+	if exemption != nil {
+		auditAnnotations[exemptionAuditAnnotation] = exemption.String()
+		if len(exemption.containers) == 0 {
+			return acceptRequest(nil, auditAnnotations)
+		}
+	}
+	namespaceSettings := settings.forNamespace(validationRequest.Request.Namespace)
+	mutated := false
+	var warnings []string
+	for _, target := range targets {
+		podSpec, podResources, err := podTargetSpec(validationRequest, object, target)
+		if err != nil {
+			return kubewarden.RejectRequest(kubewarden.Message(malformedObjectError(gvk, err).Error()), kubewarden.Code(400))
+		}
+		if podSpec == nil {
+			// The custom resource does not define the target
+			continue
+		}
+		podSettings := namespaceSettings.forPod(object.targetMetadata(target))
+		if exemption != nil {
+			podSettings = podSettings.withExemptContainers(exemption.containers)
+		}
+		if validationRequest.Request.SubResource == ephemeralContainersSubresource {
+			podSettings = podSettings.withEphemeralContainersOnly()
+		}
+		// The old object of the UPDATE requests is used to find the unchanged
+		// containers and to check the growth of the resources
+		oldPod, oldPodResources, err := oldPodSpec(validationRequest, target)
+		if err != nil {
+			return kubewarden.RejectRequest(kubewarden.Message(malformedObjectError(gvk, err).Error()), kubewarden.Code(400))
+		}
+		if oldPod != nil {
+			podSettings = podSettings.withOldPod(oldPod)
+			if podSettings.unchangedContainersAction() != UnchangedContainersEnforce {
+				podSettings = podSettings.withUnchangedContainers(unchangedContainers(podSpec, podResources, oldPod, oldPodResources))
+			}
+		}
+		mutatePod, podWarnings, err := validatePodTarget(podSpec, podResources, target, podSettings)
+		for _, warning := range podWarnings {
+			warnings = append(warnings, warning.Error())
+		}
 		if err != nil {
-			return kubewarden.RejectRequest(
-				kubewarden.Message(err.Error()),
-				kubewarden.Code(400))
+			violations = append(violations, err)
 		}
 		if mutatePod {
-			return kubewarden.MutatePodSpecFromRequest(validationRequest, podSpec)
+			if err := object.setPodTargetResources(target, podSpec); err != nil {
+				return kubewarden.RejectRequest(kubewarden.Message(err.Error()), kubewarden.Code(400))
+			}
+			mutated = true
 		}
-	} else {
-		return kubewarden.RejectRequest(kubewarden.Message(err.Error()), kubewarden.Code(400))
 	}
-	return kubewarden.AcceptRequest()
+	if err := errors.Join(violations...); err != nil {
+		details, detailsErr := violationDetails(err)
+		if detailsErr != nil {
+			return kubewarden.RejectRequest(kubewarden.Message(detailsErr.Error()), kubewarden.Code(400))
+		}
+		auditAnnotations[violationDetailsAuditAnnotation] = details
+		switch settings.mode() {
+		case ModeWarn:
+			for _, violation := range flattenErrors(err) {
+				warnings = append(warnings, violation.Error())
+			}
+		case ModeAudit:
+			auditAnnotations[violationsAuditAnnotation] = err.Error()
+		default:
+			return rejectRequest(err.Error(), 400, auditAnnotations)
+		}
+	}
+	if len(auditAnnotations) == 0 {
+		auditAnnotations = nil
+	}
+	if mutated {
+		return mutateRequest(object, warnings, auditAnnotations)
+	}
+	return acceptRequest(warnings, auditAnnotations)
 }
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/kubewarden/container-resources-policy/resource"
 	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
 	apimachinery_pkg_api_resource "github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
 )
 
 func TestContainerIsRequiredToHaveLimits(t *testing.T) {
@@ -519,6 +523,172 @@ func TestContainerIsRequiredToHaveLimits(t *testing.T) {
 				},
 			}, false, "cpu limit '1' is less than the requested '2' value",
 		},
+		{"cpu limit less than the min limit", corev1.Container{
+			Resources: &corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &oneCoreCpuQuantity,
+					"memory": &oneGiMemoryQuantity,
+				},
+				Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &oneCoreCpuQuantity,
+					"memory": &oneGiMemoryQuantity,
+				},
+			},
+		}, Settings{
+			Cpu: &ResourceConfiguration{
+				DefaultLimit:   resource.MustParse("2"),
+				DefaultRequest: resource.MustParse("2"),
+				MaxLimit:       resource.MustParse("3"),
+				MinLimit:       resource.MustParse("2"),
+			},
+			Memory: &ResourceConfiguration{
+				DefaultLimit:   oneGi,
+				DefaultRequest: oneGi,
+				MaxLimit:       oneGi,
+			},
+		}, &corev1.ResourceRequirements{
+			Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &oneCoreCpuQuantity,
+				"memory": &oneGiMemoryQuantity,
+			},
+			Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &oneCoreCpuQuantity,
+				"memory": &oneGiMemoryQuantity,
+			},
+		}, false, "cpu limit '1' is less than the min allowed value '2'"},
+		{"memory request less than the min request", corev1.Container{
+			Resources: &corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &oneCoreCpuQuantity,
+					"memory": &twoGiMemoryQuantity,
+				},
+				Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &oneCoreCpuQuantity,
+					"memory": &oneGiMemoryQuantity,
+				},
+			},
+		}, Settings{
+			Cpu: &ResourceConfiguration{
+				DefaultLimit:   oneCore,
+				DefaultRequest: oneCore,
+				MaxLimit:       oneCore,
+			},
+			Memory: &ResourceConfiguration{
+				DefaultLimit:   twoGi,
+				DefaultRequest: twoGi,
+				MaxLimit:       twoGi,
+				MinRequest:     twoGi,
+			},
+		}, &corev1.ResourceRequirements{
+			Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &oneCoreCpuQuantity,
+				"memory": &twoGiMemoryQuantity,
+			},
+			Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &oneCoreCpuQuantity,
+				"memory": &oneGiMemoryQuantity,
+			},
+		}, false, "memory request '1Gi' is less than the min allowed value '2Gi'"},
+		{"cpu request exceeding the max request without cpu limit", corev1.Container{
+			Resources: &corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"memory": &oneGiMemoryQuantity,
+				},
+				Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &twoCoreCpuQuantity,
+					"memory": &oneGiMemoryQuantity,
+				},
+			},
+		}, Settings{
+			Cpu: &ResourceConfiguration{
+				DefaultLimit:   oneCore,
+				DefaultRequest: oneCore,
+				MaxLimit:       oneCore,
+				MaxRequest:     oneCore,
+			},
+			Memory: &ResourceConfiguration{
+				DefaultLimit:   oneGi,
+				DefaultRequest: oneGi,
+				MaxLimit:       oneGi,
+			},
+		}, &corev1.ResourceRequirements{
+			Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"memory": &oneGiMemoryQuantity,
+			},
+			Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &twoCoreCpuQuantity,
+				"memory": &oneGiMemoryQuantity,
+			},
+		}, false, "cpu request '2' exceeds the max allowed request value '1'"},
+		{"memory request within the max request", corev1.Container{
+			Resources: &corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &oneCoreCpuQuantity,
+					"memory": &twoGiMemoryQuantity,
+				},
+				Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &oneCoreCpuQuantity,
+					"memory": &twoGiMemoryQuantity,
+				},
+			},
+		}, Settings{
+			Cpu: &ResourceConfiguration{
+				DefaultLimit:   oneCore,
+				DefaultRequest: oneCore,
+				MaxLimit:       oneCore,
+			},
+			Memory: &ResourceConfiguration{
+				DefaultLimit:   oneGi,
+				DefaultRequest: oneGi,
+				MaxLimit:       twoGi,
+				MaxRequest:     twoGi,
+			},
+		}, &corev1.ResourceRequirements{
+			Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &oneCoreCpuQuantity,
+				"memory": &twoGiMemoryQuantity,
+			},
+			Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &oneCoreCpuQuantity,
+				"memory": &twoGiMemoryQuantity,
+			},
+		}, false, ""},
+		{"limits and requests within the min and max values", corev1.Container{
+			Resources: &corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &oneCoreCpuQuantity,
+					"memory": &twoGiMemoryQuantity,
+				},
+				Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"cpu":    &oneCoreCpuQuantity,
+					"memory": &oneGiMemoryQuantity,
+				},
+			},
+		}, Settings{
+			Cpu: &ResourceConfiguration{
+				DefaultLimit:   oneCore,
+				DefaultRequest: oneCore,
+				MaxLimit:       oneCore,
+				MinLimit:       oneCore,
+				MinRequest:     oneCore,
+			},
+			Memory: &ResourceConfiguration{
+				DefaultLimit:   twoGi,
+				DefaultRequest: oneGi,
+				MaxLimit:       twoGi,
+				MinLimit:       oneGi,
+				MinRequest:     oneGi,
+			},
+		}, &corev1.ResourceRequirements{
+			Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &oneCoreCpuQuantity,
+				"memory": &twoGiMemoryQuantity,
+			},
+			Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu":    &oneCoreCpuQuantity,
+				"memory": &oneGiMemoryQuantity,
+			},
+		}, false, ""},
 	}
 
 	for _, test := range tests {
@@ -835,7 +1005,7 @@ func TestIgnoreImageSettings(t *testing.T) {
 	podSpec := &corev1.PodSpec{
 		Containers: []*corev1.Container{&container1, &container2, &container3},
 	}
-	mutate, err := validatePodSpec(podSpec, &settings)
+	mutate, _, err := validatePodSpec(podSpec, nil, "spec", &settings)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -924,7 +1094,7 @@ func TestIgnoreImageWithNoTags(t *testing.T) {
 	podSpec := &corev1.PodSpec{
 		Containers: []*corev1.Container{&container1, &container2, &container3},
 	}
-	mutate, err := validatePodSpec(podSpec, &settings)
+	mutate, _, err := validatePodSpec(podSpec, nil, "spec", &settings)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -979,7 +1149,7 @@ func TestImageComparison(t *testing.T) {
 		{"image", []string{"image"}, true},
 		{"image:v1", []string{"image:v2"}, false},
 		{"image:latest", []string{"image*"}, true},
-		{"image:latest", []string{"image"}, false},
+		{"image:latest", []string{"image"}, true},
 		{"image:latest", []string{"image:latest"}, true},
 		{"image:latest", []string{"otherimage:latest"}, false},
 		{"image:latest", []string{"otherimage"}, false},
@@ -997,6 +1167,28 @@ func TestImageComparison(t *testing.T) {
 		{"reg.example.com/busybox:1.23", []string{"reg.example.com/busybox:*"}, true},
 		{"busybox:latest", []string{"reg.example.com/busybox:*"}, false},
 		{"reg.example.io/busybox", []string{"reg.example.com/busybox:*"}, false},
+		{"nginx", []string{"docker.io/library/nginx:latest"}, true},
+		{"docker.io/library/nginx:latest", []string{"nginx"}, true},
+		{"index.docker.io/nginx", []string{"nginx:latest"}, true},
+		{"nginx:1.25", []string{"nginx"}, false},
+		{"myuser/app:v1", []string{"docker.io/myuser/app:v1"}, true},
+		{"registry.k8s.io/pause:3.9", []string{"*/pause:*"}, true},
+		{"quay.io/example/pause:3.9", []string{"*/pause:*"}, false},
+		{"pause:3.9", []string{"*/pause:*"}, true},
+		{"registry.k8s.io/pause:3.9", []string{"registry.k8s.io/pause:3.?"}, true},
+		{"registry.k8s.io/pause:3.10", []string{"registry.k8s.io/pause:3.?"}, false},
+		{"ghcr.io/kubewarden/policy-server:v1.10.0", []string{"ghcr.io/kubewarden/*:v1.*"}, true},
+		{"ghcr.io/other/policy-server:v1.10.0", []string{"ghcr.io/kubewarden/*:v1.*"}, false},
+		{"localhost:5000/app", []string{"localhost:5000/app:latest"}, true},
+		{"ghcr.io/kubewarden/app:v1", []string{"regex:^ghcr\\.io/kubewarden/.*:v[0-9]+$"}, true},
+		{"ghcr.io/kubewarden/app:v1-rc", []string{"regex:^ghcr\\.io/kubewarden/.*:v[0-9]+$"}, false},
+		{"nginx", []string{"regex:^docker\\.io/library/nginx:latest$"}, true},
+		{"nginx@sha256:0123456789abcdef", []string{"nginx@sha256:0123456789abcdef"}, true},
+		{"nginx:1.25@sha256:0123456789abcdef", []string{"docker.io/library/nginx@sha256:0123456789abcdef"}, true},
+		{"nginx@sha256:fedcba9876543210", []string{"nginx@sha256:0123456789abcdef"}, false},
+		{"nginx:1.25@sha256:0123456789abcdef", []string{"nginx:1.24@sha256:0123456789abcdef"}, false},
+		{"nginx@sha256:0123456789abcdef", []string{"nginx"}, false},
+		{"nginx@sha256:0123456789abcdef", []string{"nginx:*"}, true},
 	}
 	for _, test := range tests {
 		t.Run(fmt.Sprintf("%s in %v", test.image, test.ignoreImages), func(t *testing.T) {
@@ -1007,3 +1199,841 @@ func TestImageComparison(t *testing.T) {
 		})
 	}
 }
+
+func TestInitSidecarAndEphemeralContainers(t *testing.T) {
+	oneCore := resource.MustParse("1")
+	oneGi := resource.MustParse("1Gi")
+	oneCoreCpuQuantity := apimachinery_pkg_api_resource.Quantity("1")
+	oneGiMemoryQuantity := apimachinery_pkg_api_resource.Quantity("1Gi")
+	initName := "init"
+	sidecarName := "sidecar"
+	ephemeralName := "debugger"
+	defaultResources := &corev1.ResourceRequirements{
+		Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+			"cpu":    &oneCoreCpuQuantity,
+			"memory": &oneGiMemoryQuantity,
+		},
+		Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+			"cpu":    &oneCoreCpuQuantity,
+			"memory": &oneGiMemoryQuantity,
+		},
+	}
+	newPodSpec := func() *corev1.PodSpec {
+		return &corev1.PodSpec{
+			Containers: []*corev1.Container{},
+			InitContainers: []*corev1.Container{
+				{Name: &initName, Image: "init:latest"},
+				{Name: &sidecarName, Image: "sidecar:latest", RestartPolicy: "Always"},
+			},
+			EphemeralContainers: []*corev1.EphemeralContainer{
+				{Name: &ephemeralName, Image: "busybox:latest"},
+			},
+		}
+	}
+	tests := []struct {
+		name                       string
+		settings                   Settings
+		expectedInitResources      *corev1.ResourceRequirements
+		expectedSidecarResources   *corev1.ResourceRequirements
+		expectedEphemeralResources *corev1.ResourceRequirements
+		shouldMutate               bool
+		expectedErrorMsg           string
+	}{
+		{
+			"init and sidecar containers are mutated by default",
+			Settings{},
+			defaultResources, defaultResources, nil, true, "",
+		},
+		{
+			"ephemeral containers are skipped",
+			Settings{EphemeralContainers: &ContainerKindConfiguration{Action: ContainerActionSkip}},
+			defaultResources, defaultResources, nil, true, "",
+		},
+		{
+			"skip init and sidecar containers",
+			Settings{
+				InitContainers:    &ContainerKindConfiguration{Action: ContainerActionSkip},
+				SidecarContainers: &ContainerKindConfiguration{Action: ContainerActionSkip},
+			},
+			nil, nil, nil, false, "",
+		},
+		{
+			"sidecar containers are validated only",
+			Settings{
+				InitContainers:    &ContainerKindConfiguration{Action: ContainerActionSkip},
+				SidecarContainers: &ContainerKindConfiguration{Action: ContainerActionValidate},
+			},
+			nil, nil, nil, false, "spec.initContainers[1].resources (sidecar container 'sidecar'): container does not define all the required resources and mutation is disabled for this kind of container",
+		},
+		{
+			"ephemeral containers are validated only",
+			Settings{
+				InitContainers:      &ContainerKindConfiguration{Action: ContainerActionSkip},
+				SidecarContainers:   &ContainerKindConfiguration{Action: ContainerActionSkip},
+				EphemeralContainers: &ContainerKindConfiguration{Action: ContainerActionValidate},
+			},
+			nil, nil, nil, false, "spec.ephemeralContainers[0].resources (ephemeral container 'debugger')",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.settings.Cpu = &ResourceConfiguration{
+				DefaultLimit:   oneCore,
+				DefaultRequest: oneCore,
+				MaxLimit:       oneCore,
+			}
+			test.settings.Memory = &ResourceConfiguration{
+				DefaultLimit:   oneGi,
+				DefaultRequest: oneGi,
+				MaxLimit:       oneGi,
+			}
+			podSpec := newPodSpec()
+			mutated, _, err := validatePodSpec(podSpec, nil, "spec", &test.settings)
+			if err != nil && len(test.expectedErrorMsg) == 0 {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if len(test.expectedErrorMsg) > 0 {
+				if err == nil {
+					t.Fatalf("expected error message with string '%s'. But no error has been returned", test.expectedErrorMsg)
+				}
+				if !strings.Contains(err.Error(), test.expectedErrorMsg) {
+					t.Fatalf("invalid error message. Expected the string '%s' in the error. Got '%s'", test.expectedErrorMsg, err.Error())
+				}
+				return
+			}
+			if mutated != test.shouldMutate {
+				t.Fatalf("validation function does not report mutation flag correctly. Got: %t, expected: %t", mutated, test.shouldMutate)
+			}
+			if diff := cmp.Diff(test.expectedInitResources, podSpec.InitContainers[0].Resources); diff != "" {
+				t.Errorf("invalid init container resources:\n %s", diff)
+			}
+			if diff := cmp.Diff(test.expectedSidecarResources, podSpec.InitContainers[1].Resources); diff != "" {
+				t.Errorf("invalid sidecar container resources:\n %s", diff)
+			}
+			if diff := cmp.Diff(test.expectedEphemeralResources, podSpec.EphemeralContainers[0].Resources); diff != "" {
+				t.Errorf("invalid ephemeral container resources:\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestInitContainerExceedingMaxLimit(t *testing.T) {
+	oneCore := resource.MustParse("1")
+	twoCoreCpuQuantity := apimachinery_pkg_api_resource.Quantity("2")
+	initName := "init"
+	podSpec := &corev1.PodSpec{
+		InitContainers: []*corev1.Container{
+			{
+				Name:  &initName,
+				Image: "init:latest",
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"cpu": &twoCoreCpuQuantity,
+					},
+				},
+			},
+		},
+	}
+	settings := Settings{
+		Cpu: &ResourceConfiguration{
+			DefaultLimit:   oneCore,
+			DefaultRequest: oneCore,
+			MaxLimit:       oneCore,
+		},
+	}
+	_, _, err := validatePodSpec(podSpec, nil, "spec", &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	expectedErrorMsg := "spec.initContainers[0].resources.limits.cpu (init container 'init'): cpu limit '2' exceeds the max allowed value '1'"
+	if err.Error() != expectedErrorMsg {
+		t.Errorf("invalid error message. Expected '%s'. Got '%s'", expectedErrorMsg, err.Error())
+	}
+}
+
+func TestLimitRequestRatio(t *testing.T) {
+	tests := []struct {
+		name             string
+		limit            string
+		request          string
+		maxRatio         string
+		minRatio         string
+		expectedErrorMsg string
+	}{
+		{"ratio within the max ratio", "4", "1", "4", "0", ""},
+		{"ratio exceeding the max ratio", "4", "10m", "4", "0", "cpu limit '4' to request '10m' ratio exceeds the max allowed ratio '4'"},
+		{"fractional ratio exceeding the max ratio", "1001m", "1", "1", "0", "cpu limit '1001m' to request '1' ratio exceeds the max allowed ratio '1'"},
+		{"fractional max ratio", "3", "2", "1500m", "0", ""},
+		{"ratio less than the min ratio", "1", "1", "0", "2", "cpu limit '1' to request '1' ratio is less than the min allowed ratio '2'"},
+		{"ratio within the min and max ratio", "3", "1", "4", "2", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limit := apimachinery_pkg_api_resource.Quantity(test.limit)
+			request := apimachinery_pkg_api_resource.Quantity(test.request)
+			container := corev1.Container{
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"cpu": &limit,
+					},
+					Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"cpu": &request,
+					},
+				},
+			}
+			settings := Settings{
+				Cpu: &ResourceConfiguration{
+					MaxLimit:             resource.MustParse("4"),
+					MaxLimitRequestRatio: resource.MustParse(test.maxRatio),
+					MinLimitRequestRatio: resource.MustParse(test.minRatio),
+				},
+			}
+			_, err := validateAndAdjustContainer(&container, &settings)
+			if err != nil && len(test.expectedErrorMsg) == 0 {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if len(test.expectedErrorMsg) > 0 {
+				if err == nil {
+					t.Fatalf("expected error message with string '%s'. But no error has been returned", test.expectedErrorMsg)
+				}
+				if !strings.Contains(err.Error(), test.expectedErrorMsg) {
+					t.Errorf("invalid error message. Expected the string '%s' in the error. Got '%s'", test.expectedErrorMsg, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestLimitRequestRatioAfterDefaulting(t *testing.T) {
+	oneCoreCpuQuantity := apimachinery_pkg_api_resource.Quantity("1")
+	container := corev1.Container{
+		Resources: &corev1.ResourceRequirements{
+			Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+				"cpu": &oneCoreCpuQuantity,
+			},
+		},
+	}
+	settings := Settings{
+		Cpu: &ResourceConfiguration{
+			MaxLimit:             resource.MustParse("4"),
+			DefaultLimit:         resource.MustParse("1"),
+			DefaultRequest:       resource.MustParse("100m"),
+			MaxLimitRequestRatio: resource.MustParse("4"),
+		},
+	}
+	_, err := validateAndAdjustContainer(&container, &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	expectedErrorMsg := "cpu limit '1' to request '100m' ratio exceeds the max allowed ratio '4'"
+	if err.Error() != expectedErrorMsg {
+		t.Errorf("invalid error message. Expected '%s'. Got '%s'", expectedErrorMsg, err.Error())
+	}
+}
+
+func TestGenericResources(t *testing.T) {
+	oneGiQuantity := apimachinery_pkg_api_resource.Quantity("1Gi")
+	twoGiQuantity := apimachinery_pkg_api_resource.Quantity("2Gi")
+	oneQuantity := apimachinery_pkg_api_resource.Quantity("1")
+	twoQuantity := apimachinery_pkg_api_resource.Quantity("2")
+	settings := Settings{
+		Resources: map[string]*ResourceConfiguration{
+			"ephemeral-storage": {
+				MaxLimit:       resource.MustParse("1Gi"),
+				DefaultLimit:   resource.MustParse("1Gi"),
+				DefaultRequest: resource.MustParse("1Gi"),
+			},
+			"example.com/fpga": {
+				MaxLimit:       resource.MustParse("2"),
+				DefaultLimit:   resource.MustParse("1"),
+				DefaultRequest: resource.MustParse("1"),
+			},
+			"hugepages-2Mi": {
+				IgnoreValues: true,
+			},
+		},
+	}
+	tests := []struct {
+		name                  string
+		container             corev1.Container
+		expectedResouceLimits *corev1.ResourceRequirements
+		shouldMutate          bool
+		expectedErrorMsg      string
+	}{
+		{
+			"missing hugepages",
+			corev1.Container{
+				Resources: &corev1.ResourceRequirements{},
+			},
+			nil, false, "container does not have a hugepages-2Mi limit",
+		},
+		{
+			"default values are applied",
+			corev1.Container{
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"hugepages-2Mi": &twoGiQuantity,
+					},
+					Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"hugepages-2Mi": &twoGiQuantity,
+					},
+				},
+			},
+			&corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"ephemeral-storage": &oneGiQuantity,
+					"example.com/fpga":  &oneQuantity,
+					"hugepages-2Mi":     &twoGiQuantity,
+				},
+				Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"ephemeral-storage": &oneGiQuantity,
+					"example.com/fpga":  &oneQuantity,
+					"hugepages-2Mi":     &twoGiQuantity,
+				},
+			}, true, "",
+		},
+		{
+			"extended resource request is copied from the limit",
+			corev1.Container{
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"ephemeral-storage": &oneGiQuantity,
+						"example.com/fpga":  &twoQuantity,
+						"hugepages-2Mi":     &twoGiQuantity,
+					},
+					Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"ephemeral-storage": &oneGiQuantity,
+						"hugepages-2Mi":     &twoGiQuantity,
+					},
+				},
+			},
+			&corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"ephemeral-storage": &oneGiQuantity,
+					"example.com/fpga":  &twoQuantity,
+					"hugepages-2Mi":     &twoGiQuantity,
+				},
+				Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"ephemeral-storage": &oneGiQuantity,
+					"example.com/fpga":  &twoQuantity,
+					"hugepages-2Mi":     &twoGiQuantity,
+				},
+			}, true, "",
+		},
+		{
+			"extended resource limit is copied from the request",
+			corev1.Container{
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"ephemeral-storage": &oneGiQuantity,
+						"hugepages-2Mi":     &twoGiQuantity,
+					},
+					Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"ephemeral-storage": &oneGiQuantity,
+						"example.com/fpga":  &twoQuantity,
+						"hugepages-2Mi":     &twoGiQuantity,
+					},
+				},
+			},
+			&corev1.ResourceRequirements{
+				Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"ephemeral-storage": &oneGiQuantity,
+					"example.com/fpga":  &twoQuantity,
+					"hugepages-2Mi":     &twoGiQuantity,
+				},
+				Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+					"ephemeral-storage": &oneGiQuantity,
+					"example.com/fpga":  &twoQuantity,
+					"hugepages-2Mi":     &twoGiQuantity,
+				},
+			}, true, "",
+		},
+		{
+			"ephemeral storage exceeding the max limit",
+			corev1.Container{
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"ephemeral-storage": &twoGiQuantity,
+						"hugepages-2Mi":     &twoGiQuantity,
+					},
+					Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"hugepages-2Mi": &twoGiQuantity,
+					},
+				},
+			},
+			nil, false, "ephemeral-storage limit '2Gi' exceeds the max allowed value '1Gi'",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateContainerResources(&test.container, &settings)
+			if err == nil {
+				var mutated bool
+				mutated, err = validateAndAdjustContainer(&test.container, &settings)
+				if mutated != test.shouldMutate {
+					t.Errorf("validation function does not report mutation flag correctly. Got: %t, expected: %t", mutated, test.shouldMutate)
+				}
+			}
+			if err != nil && len(test.expectedErrorMsg) == 0 {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if len(test.expectedErrorMsg) > 0 {
+				if err == nil {
+					t.Fatalf("expected error message with string '%s'. But no error has been returned", test.expectedErrorMsg)
+				}
+				if !strings.Contains(err.Error(), test.expectedErrorMsg) {
+					t.Fatalf("invalid error message. Expected the string '%s' in the error. Got '%s'", test.expectedErrorMsg, err.Error())
+				}
+				return
+			}
+			if diff := cmp.Diff(test.expectedResouceLimits, test.container.Resources); diff != "" {
+				t.Errorf("%s", diff)
+			}
+		})
+	}
+}
+
+func TestAllViolationsAreReported(t *testing.T) {
+	oneCore := resource.MustParse("1")
+	oneGi := resource.MustParse("1Gi")
+	oneCoreCpuQuantity := apimachinery_pkg_api_resource.Quantity("1")
+	twoCoreCpuQuantity := apimachinery_pkg_api_resource.Quantity("2")
+	oneGiMemoryQuantity := apimachinery_pkg_api_resource.Quantity("1Gi")
+	twoGiMemoryQuantity := apimachinery_pkg_api_resource.Quantity("2Gi")
+	appName := "app"
+	sidecarName := "sidecar"
+	initName := "init"
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{
+			{
+				Name: &appName,
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"cpu":    &oneCoreCpuQuantity,
+						"memory": &oneGiMemoryQuantity,
+					},
+				},
+			},
+			{
+				Name: &sidecarName,
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"cpu":    &twoCoreCpuQuantity,
+						"memory": &twoGiMemoryQuantity,
+					},
+				},
+			},
+		},
+		InitContainers: []*corev1.Container{
+			{
+				Name: &initName,
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"cpu": &twoCoreCpuQuantity,
+					},
+				},
+			},
+		},
+	}
+	settings := Settings{
+		Cpu: &ResourceConfiguration{
+			DefaultLimit:   oneCore,
+			DefaultRequest: oneCore,
+			MaxLimit:       oneCore,
+		},
+		Memory: &ResourceConfiguration{
+			DefaultLimit:   oneGi,
+			DefaultRequest: oneGi,
+			MaxLimit:       oneGi,
+		},
+	}
+	_, _, err := validatePodSpec(podSpec, nil, "spec.template.spec", &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	expectedErrorMsg := strings.Join([]string{
+		"spec.template.spec.containers[1].resources.limits.cpu (container 'sidecar'): cpu limit '2' exceeds the max allowed value '1'",
+		"spec.template.spec.containers[1].resources.limits.memory (container 'sidecar'): memory limit '2Gi' exceeds the max allowed value '1Gi'",
+		"spec.template.spec.initContainers[0].resources.limits.cpu (init container 'init'): cpu limit '2' exceeds the max allowed value '1'",
+	}, "\n")
+	if err.Error() != expectedErrorMsg {
+		t.Errorf("invalid error message. Expected:\n%s\nGot:\n%s", expectedErrorMsg, err.Error())
+	}
+}
+
+func TestAllMissingResourcesAreReported(t *testing.T) {
+	container := corev1.Container{
+		Resources: &corev1.ResourceRequirements{
+			Limits:   map[string]*apimachinery_pkg_api_resource.Quantity{},
+			Requests: map[string]*apimachinery_pkg_api_resource.Quantity{},
+		},
+	}
+	settings := Settings{
+		Cpu:    &ResourceConfiguration{IgnoreValues: true},
+		Memory: &ResourceConfiguration{IgnoreValues: true},
+		Resources: map[string]*ResourceConfiguration{
+			"example.com/fpga": {IgnoreValues: true},
+		},
+	}
+	err := validateContainerResources(&container, &settings)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	expectedFields := []string{
+		"resources.limits.cpu",
+		"resources.limits[example.com/fpga]",
+		"resources.limits.memory",
+		"resources.requests.cpu",
+		"resources.requests[example.com/fpga]",
+		"resources.requests.memory",
+	}
+	errs := flattenErrors(err)
+	if len(errs) != len(expectedFields) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expectedFields), len(errs), err)
+	}
+	for i, e := range errs {
+		fe, ok := e.(fieldError)
+		if !ok {
+			t.Fatalf("expected a field error, got %T", e)
+		}
+		if fe.field != expectedFields[i] {
+			t.Errorf("invalid field. Expected '%s', got '%s'", expectedFields[i], fe.field)
+		}
+	}
+}
+
+func TestPodSpecPath(t *testing.T) {
+	tests := []struct {
+		kind         string
+		expectedPath string
+	}{
+		{"Pod", "spec"},
+		{"Deployment", "spec.template.spec"},
+		{"StatefulSet", "spec.template.spec"},
+		{"Job", "spec.template.spec"},
+		{"CronJob", "spec.jobTemplate.spec.template.spec"},
+	}
+	for _, test := range tests {
+		t.Run(test.kind, func(t *testing.T) {
+			if path := podSpecPath(test.kind); path != test.expectedPath {
+				t.Errorf("invalid path. Expected '%s', got '%s'", test.expectedPath, path)
+			}
+		})
+	}
+}
+
+// validateTestRequest runs the policy against the admission request stored in
+// the given file, using the given settings. The request can be changed by the
+// update function before the validation
+func validateTestRequest(t *testing.T, requestFile, rawSettings string, update func(*kubewarden_protocol.KubernetesAdmissionRequest)) ValidationResponse {
+	t.Helper()
+	rawRequest, err := os.ReadFile(requestFile)
+	if err != nil {
+		t.Fatalf("cannot read the request file: %v", err)
+	}
+	validationRequest := kubewarden_protocol.ValidationRequest{Settings: json.RawMessage(rawSettings)}
+	if err := json.Unmarshal(rawRequest, &validationRequest.Request); err != nil {
+		t.Fatalf("cannot parse the request: %v", err)
+	}
+	if update != nil {
+		update(&validationRequest.Request)
+	}
+	payload, err := json.Marshal(validationRequest)
+	if err != nil {
+		t.Fatalf("cannot marshal the request: %v", err)
+	}
+	rawResponse, err := validate(payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response := ValidationResponse{}
+	if err := json.Unmarshal(rawResponse, &response); err != nil {
+		t.Fatalf("cannot parse the response: %v", err)
+	}
+	return response
+}
+
+func TestEnforcementModes(t *testing.T) {
+	exceedingSettings := `{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "mode": "%s"}`
+	defaultsSettings := `{"cpu": {"maxLimit": "1", "defaultRequest": "100m", "defaultLimit": "200m"}, "mode": "%s", "disableMutation": %t}`
+	tests := []struct {
+		name                   string
+		requestFile            string
+		rawSettings            string
+		expectedAccepted       bool
+		expectedMutation       bool
+		expectedMessage        string
+		expectedWarnings       int
+		expectedAuditViolation string
+	}{
+		{"enforce mode rejects violations", "test_data/pod_exceeding_range.json", fmt.Sprintf(exceedingSettings, ModeEnforce), false, false, "exceeds the max allowed value", 0, ""},
+		{"enforce is the default mode", "test_data/pod_exceeding_range.json", fmt.Sprintf(exceedingSettings, ""), false, false, "exceeds the max allowed value", 0, ""},
+		{"warn mode returns violations as warnings", "test_data/pod_exceeding_range.json", fmt.Sprintf(exceedingSettings, ModeWarn), true, false, "", 2, ""},
+		{"audit mode records violations in the audit annotations", "test_data/pod_exceeding_range.json", fmt.Sprintf(exceedingSettings, ModeAudit), true, false, "", 0, "exceeds the max allowed value"},
+		{"warn mode mutates the valid containers", "test_data/pod_without_resources.json", fmt.Sprintf(defaultsSettings, ModeWarn, false), true, true, "", 0, ""},
+		{"disabled mutation rejects containers requiring mutation", "test_data/pod_without_resources.json", fmt.Sprintf(defaultsSettings, ModeEnforce, true), false, false, "mutation is disabled", 0, ""},
+		{"clamped limits are returned as warnings", "test_data/pod_exceeding_range.json", `{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m", "onExceed": "clamp"}}`, true, true, "", 4, ""},
+		{"disabled mutation in warn mode", "test_data/pod_without_resources.json", fmt.Sprintf(defaultsSettings, ModeWarn, true), true, false, "", 2, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validateTestRequest(t, test.requestFile, test.rawSettings, nil)
+			if response.Accepted != test.expectedAccepted {
+				t.Fatalf("expected accepted to be %t, got %t: %v", test.expectedAccepted, response.Accepted, response.Message)
+			}
+			if test.expectedMessage != "" && (response.Message == nil || !strings.Contains(*response.Message, test.expectedMessage)) {
+				t.Errorf("expected message containing '%s', got %v", test.expectedMessage, response.Message)
+			}
+			if (response.MutatedObject != nil) != test.expectedMutation {
+				t.Errorf("expected mutation to be %t, got mutated object %v", test.expectedMutation, response.MutatedObject)
+			}
+			if len(response.Warnings) != test.expectedWarnings {
+				t.Errorf("expected %d warnings, got %v", test.expectedWarnings, response.Warnings)
+			}
+			violations := response.AuditAnnotations[violationsAuditAnnotation]
+			if test.expectedAuditViolation == "" && violations != "" || !strings.Contains(violations, test.expectedAuditViolation) {
+				t.Errorf("expected audit violations containing '%s', got '%s'", test.expectedAuditViolation, violations)
+			}
+		})
+	}
+}
+
+func TestClampLimits(t *testing.T) {
+	tests := []struct {
+		name             string
+		limit            string
+		request          string
+		onExceed         string
+		maxRequest       string
+		disableMutation  bool
+		expectedLimit    string
+		expectedRequest  string
+		expectedWarnings []string
+		expectedErrorMsg string
+	}{
+		{"limit within the max limit", "1", "500m", OnExceedClamp, "", false, "1", "500m", nil, ""},
+		{"limit clamped", "3", "1", OnExceedClamp, "", false, "2", "1", []string{"cpu limit '3' exceeds the max allowed value and has been lowered to '2'"}, ""},
+		{"limit and request clamped", "3", "3", OnExceedClamp, "", false, "2", "2", []string{"cpu limit '3' exceeds the max allowed value and has been lowered to '2'", "cpu request '3' has been lowered to the new limit '2'"}, ""},
+		{"invalid request is not clamped", "3", "3", OnExceedClamp, "2500m", false, "3", "3", nil, "cpu request '3' exceeds the max allowed request value '2500m'"},
+		{"limit rejected by default", "3", "1", "", "", false, "3", "1", nil, "cpu limit '3' exceeds the max allowed value '2'"},
+		{"limit rejected when mutation is disabled", "3", "1", OnExceedClamp, "", true, "3", "1", nil, "cpu limit '3' exceeds the max allowed value '2'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limit := apimachinery_pkg_api_resource.Quantity(test.limit)
+			request := apimachinery_pkg_api_resource.Quantity(test.request)
+			container := corev1.Container{
+				Resources: &corev1.ResourceRequirements{
+					Limits: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"cpu": &limit,
+					},
+					Requests: map[string]*apimachinery_pkg_api_resource.Quantity{
+						"cpu": &request,
+					},
+				},
+			}
+			settings := Settings{
+				Cpu: &ResourceConfiguration{
+					MaxLimit:       resource.MustParse("2"),
+					DefaultLimit:   resource.MustParse("1"),
+					DefaultRequest: resource.MustParse("500m"),
+					OnExceed:       test.onExceed,
+				},
+				DisableMutation: test.disableMutation,
+			}
+			if test.maxRequest != "" {
+				settings.Cpu.MaxRequest = resource.MustParse(test.maxRequest)
+			}
+			_, warnings, err := validateContainer(&container, ContainerActionDefault, &settings)
+			if err != nil && len(test.expectedErrorMsg) == 0 {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if len(test.expectedErrorMsg) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectedErrorMsg)) {
+				t.Errorf("invalid error message. Expected the string '%s' in the error. Got '%v'", test.expectedErrorMsg, err)
+			}
+			warningMessages := []string{}
+			for _, warning := range warnings {
+				warningMessages = append(warningMessages, warning.Error())
+			}
+			if diff := cmp.Diff(test.expectedWarnings, warningMessages, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("invalid warnings: %s", diff)
+			}
+			if string(*container.Resources.Limits["cpu"]) != test.expectedLimit || string(*container.Resources.Requests["cpu"]) != test.expectedRequest {
+				t.Errorf("expected limit '%s' and request '%s', got limit '%s' and request '%s'", test.expectedLimit, test.expectedRequest,
+					*container.Resources.Limits["cpu"], *container.Resources.Requests["cpu"])
+			}
+		})
+	}
+}
+
+func TestClampedLimitRequestRatio(t *testing.T) {
+	limit := apimachinery_pkg_api_resource.Quantity("4")
+	request := apimachinery_pkg_api_resource.Quantity("1500m")
+	container := corev1.Container{
+		Resources: &corev1.ResourceRequirements{
+			Limits:   map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &limit},
+			Requests: map[string]*apimachinery_pkg_api_resource.Quantity{"cpu": &request},
+		},
+	}
+	settings := Settings{
+		Cpu: &ResourceConfiguration{
+			MaxLimit:             resource.MustParse("2"),
+			MaxLimitRequestRatio: resource.MustParse("1200m"),
+			DefaultLimit:         resource.MustParse("1"),
+			DefaultRequest:       resource.MustParse("1"),
+			OnExceed:             OnExceedClamp,
+		},
+	}
+	_, _, err := validateContainer(&container, ContainerActionDefault, &settings)
+	// The limit defined by the user is reported, besides the clamped one
+	expectedErrorMsg := "cpu limit '4' has been clamped to '2': cpu limit '2' to request '1500m' ratio exceeds the max allowed ratio '1200m'"
+	if err == nil || !strings.Contains(err.Error(), expectedErrorMsg) {
+		t.Errorf("invalid error message. Expected the string '%s' in the error. Got '%v'", expectedErrorMsg, err)
+	}
+	if string(*container.Resources.Limits["cpu"]) != "4" {
+		t.Errorf("the invalid container was mutated: %v", *container.Resources.Limits["cpu"])
+	}
+}
+
+func TestLimitMode(t *testing.T) {
+	tests := []struct {
+		name             string
+		limit            string
+		request          string
+		limitMode        string
+		ignoreValues     bool
+		disableMutation  bool
+		expectedLimit    string
+		expectedRequest  string
+		expectedWarnings []string
+		expectedErrorMsg string
+	}{
+		{"limit removed", "2", "500m", LimitModeStrip, false, false, "", "500m", []string{"cpu limits are not allowed. The limit has been removed"}, ""},
+		{"default request without limit", "", "", LimitModeStrip, false, false, "", "100m", nil, ""},
+		{"request exceeding the max request", "", "2", LimitModeStrip, false, false, "", "2", nil, "cpu request '2' exceeds the max allowed request value '1'"},
+		{"limit forbidden", "2", "500m", LimitModeForbid, false, false, "2", "500m", nil, "cpu limits are not allowed"},
+		{"request without limit", "", "500m", LimitModeForbid, false, false, "", "500m", nil, ""},
+		{"missing request when only the presence is checked", "2", "", LimitModeStrip, true, false, "2", "", nil, "container does not have a cpu request"},
+		{"limit removed when only the presence is checked", "2", "500m", LimitModeStrip, true, false, "", "500m", []string{"cpu limits are not allowed. The limit has been removed"}, ""},
+		{"limit not removed when mutation is disabled", "2", "500m", LimitModeStrip, false, true, "2", "500m", nil, "cpu limits are not allowed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container := corev1.Container{
+				Resources: &corev1.ResourceRequirements{
+					Limits:   map[string]*apimachinery_pkg_api_resource.Quantity{},
+					Requests: map[string]*apimachinery_pkg_api_resource.Quantity{},
+				},
+			}
+			if test.limit != "" {
+				limit := apimachinery_pkg_api_resource.Quantity(test.limit)
+				container.Resources.Limits["cpu"] = &limit
+			}
+			if test.request != "" {
+				request := apimachinery_pkg_api_resource.Quantity(test.request)
+				container.Resources.Requests["cpu"] = &request
+			}
+			settings := Settings{
+				Cpu: &ResourceConfiguration{
+					MaxRequest:     resource.MustParse("1"),
+					DefaultRequest: resource.MustParse("100m"),
+					LimitMode:      test.limitMode,
+					IgnoreValues:   test.ignoreValues,
+				},
+				DisableMutation: test.disableMutation,
+			}
+			_, warnings, err := validateContainer(&container, ContainerActionDefault, &settings)
+			if err != nil && len(test.expectedErrorMsg) == 0 {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if len(test.expectedErrorMsg) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectedErrorMsg)) {
+				t.Errorf("invalid error message. Expected the string '%s' in the error. Got '%v'", test.expectedErrorMsg, err)
+			}
+			warningMessages := []string{}
+			for _, warning := range warnings {
+				warningMessages = append(warningMessages, warning.Error())
+			}
+			if diff := cmp.Diff(test.expectedWarnings, warningMessages, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("invalid warnings: %s", diff)
+			}
+			quantity := func(quantities map[string]*apimachinery_pkg_api_resource.Quantity) string {
+				if quantities["cpu"] == nil {
+					return ""
+				}
+				return string(*quantities["cpu"])
+			}
+			if limit, request := quantity(container.Resources.Limits), quantity(container.Resources.Requests); limit != test.expectedLimit || request != test.expectedRequest {
+				t.Errorf("expected limit '%s' and request '%s', got limit '%s' and request '%s'", test.expectedLimit, test.expectedRequest, limit, request)
+			}
+		})
+	}
+}
+
+func TestBoundsWithoutMaxLimit(t *testing.T) {
+	tests := []struct {
+		name             string
+		requestFile      string
+		rawSettings      string
+		expectedErrorMsg string
+	}{
+		{"max request only", "test_data/pod_exceeding_range.json", `{"memory": {"maxRequest": "1Gi"}}`, "memory request '3Gi' exceeds the max allowed request value '1Gi'"},
+		{"request within the max request", "test_data/pod_within_range.json", `{"memory": {"maxRequest": "2Gi"}}`, ""},
+		{"request bounds next to another resource", "test_data/pod_exceeding_range.json", `{"cpu": {"maxLimit": "1", "defaultRequest": "1m", "defaultLimit": "1m"}, "memory": {"maxRequest": "1Gi", "minRequest": "10Mi"}}`, "memory request '3Gi' exceeds the max allowed request value '1Gi'"},
+		{"min limit only", "test_data/pod_within_range.json", `{"memory": {"minLimit": "1500Mi"}}`, "memory limit '1Gi' is less than the min allowed value '1500Mi'"},
+		{"min limit next to another resource", "test_data/pod_within_range.json", `{"cpu": {"maxLimit": "1", "defaultRequest": "1m", "defaultLimit": "1m"}, "memory": {"minLimit": "1500Mi"}}`, "memory limit '1Gi' is less than the min allowed value '1500Mi'"},
+		{"min request only", "test_data/pod_within_range.json", `{"memory": {"minRequest": "1500Mi"}}`, "memory request '1Gi' is less than the min allowed value '1500Mi'"},
+		{"limit request ratio only", "test_data/pod_within_range.json", `{"memory": {"minLimitRequestRatio": "2"}}`, "memory limit '1Gi' to request '1Gi' ratio is less than the min allowed ratio '2'"},
+		{"limit request ratio next to another resource", "test_data/pod_within_range.json", `{"cpu": {"maxLimit": "1", "defaultRequest": "1m", "defaultLimit": "1m"}, "memory": {"maxLimitRequestRatio": "2"}}`, ""},
+		{"request less than the min request", "test_data/pod_within_range.json", `{"cpu": {"maxLimit": "1", "defaultRequest": "1m", "defaultLimit": "1m"}, "memory": {"maxRequest": "4Gi", "minRequest": "1500Mi"}}`, "memory request '1Gi' is less than the min allowed value '1500Mi'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validateTestRequest(t, test.requestFile, test.rawSettings, nil)
+			if test.expectedErrorMsg == "" {
+				if !response.Accepted || response.MutatedObject != nil {
+					t.Errorf("expected the request to be accepted without mutation: %v", response.Message)
+				}
+				return
+			}
+			if response.Accepted {
+				t.Fatal("the request should be rejected")
+			}
+			if !strings.Contains(*response.Message, test.expectedErrorMsg) {
+				t.Errorf("invalid message. Expected the string '%s', got %s", test.expectedErrorMsg, *response.Message)
+			}
+		})
+	}
+}
+
+func TestEphemeralContainersSubresource(t *testing.T) {
+	// The regular containers of the pod exceed the max limit
+	rawSettings := `{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "ephemeralContainers": {"action": "%s"}}`
+	addEphemeralContainer := func(request *kubewarden_protocol.KubernetesAdmissionRequest) {
+		object := map[string]interface{}{}
+		if err := json.Unmarshal(request.Object, &object); err != nil {
+			t.Fatalf("cannot parse the object: %v", err)
+		}
+		spec := object["spec"].(map[string]interface{})
+		spec["ephemeralContainers"] = []interface{}{map[string]interface{}{"name": "debugger", "image": "busybox"}}
+		payload, err := json.Marshal(object)
+		if err != nil {
+			t.Fatalf("cannot marshal the object: %v", err)
+		}
+		request.Object = payload
+		request.Operation = "UPDATE"
+		request.SubResource = "ephemeralcontainers"
+	}
+
+	response := validateTestRequest(t, "test_data/pod_exceeding_range.json", fmt.Sprintf(rawSettings, ContainerActionSkip), addEphemeralContainer)
+	if !response.Accepted || response.MutatedObject != nil {
+		t.Errorf("expected the request to be accepted without mutation: %v", response.Message)
+	}
+
+	response = validateTestRequest(t, "test_data/pod_exceeding_range.json", fmt.Sprintf(rawSettings, ContainerActionValidate), addEphemeralContainer)
+	if response.Accepted {
+		t.Fatal("the request should be rejected")
+	}
+	if strings.Contains(*response.Message, "spec.containers") || !strings.Contains(*response.Message, "spec.ephemeralContainers[0].resources (ephemeral container 'debugger')") {
+		t.Errorf("only the ephemeral containers should be validated: %s", *response.Message)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Rules checked by the policy. They identify the violations in the
+// structured details
+const (
+	RuleRequiredResources       = "requiredResources"
+	RuleRequiredLimit           = "requiredLimit"
+	RuleRequiredRequest         = "requiredRequest"
+	RuleForbiddenLimit          = "forbiddenLimit"
+	RuleInvalidQuantity         = "invalidQuantity"
+	RuleMaxLimit                = "maxLimit"
+	RuleMinLimit                = "minLimit"
+	RuleMaxRequest              = "maxRequest"
+	RuleMinRequest              = "minRequest"
+	RuleMaxLimitRequestRatio    = "maxLimitRequestRatio"
+	RuleMinLimitRequestRatio    = "minLimitRequestRatio"
+	RuleLimitGreaterThanRequest = "limitGreaterThanRequest"
+	RuleMutationDisabled        = "mutationDisabled"
+	RulePodMaxLimit             = "podMaxLimit"
+	RulePodMaxRequest           = "podMaxRequest"
+	RuleQosClass                = "qosClass"
+	RuleMaxGrowthFactor         = "maxGrowthFactor"
+	RuleMaxGrowthDelta          = "maxGrowthDelta"
+	RuleExemptionAnnotation     = "exemptionAnnotation"
+)
+
+// Audit annotation with the structured details of the violations
+const violationDetailsAuditAnnotation = "violation-details"
+
+// ruleError is an error breaking one of the rules of the policy. Besides the
+// human readable message, it carries the values reported in the structured
+// details of the violation
+type ruleError struct {
+	rule     string
+	resource string
+	actual   string
+	bound    string
+	message  string
+}
+
+func (e ruleError) Error() string {
+	return e.message
+}
+
+// newRuleError returns a ruleError with the message built from the given
+// format and arguments
+func newRuleError(rule, resourceName, actual, bound, format string, args ...interface{}) error {
+	return ruleError{rule: rule, resource: resourceName, actual: actual, bound: bound, message: fmt.Sprintf(format, args...)}
+}
+
+// invalidQuantityError reports a quantity which cannot be parsed
+func invalidQuantityError(resourceName, quantity, format string, args ...interface{}) error {
+	return newRuleError(RuleInvalidQuantity, resourceName, quantity, "", format, args...)
+}
+
+// violationError is a violation found in a pod. The field is the full path
+// of the invalid field. The container is not defined for the pod violations
+type violationError struct {
+	field         string
+	container     string
+	containerKind string
+	err           error
+}
+
+func (e violationError) Error() string {
+	if e.containerKind == "" {
+		return fmt.Sprintf("%s: %s", e.field, e.err)
+	}
+	return fmt.Sprintf("%s (%s '%s'): %s", e.field, e.containerKind, e.container, e.err)
+}
+
+func (e violationError) Unwrap() error {
+	return e.err
+}
+
+// Violation is the structured description of a violation
+type Violation struct {
+	Field         string `json:"field"`
+	Container     string `json:"container,omitempty"`
+	ContainerKind string `json:"containerKind,omitempty"`
+	Resource      string `json:"resource,omitempty"`
+	Rule          string `json:"rule,omitempty"`
+	Actual        string `json:"actual,omitempty"`
+	Bound         string `json:"bound,omitempty"`
+	Message       string `json:"message"`
+}
+
+// violation returns the structured description of the violation
+func (e violationError) violation() Violation {
+	violation := Violation{
+		Field:         e.field,
+		Container:     e.container,
+		ContainerKind: e.containerKind,
+		Message:       e.Error(),
+	}
+	var ruleErr ruleError
+	if errors.As(e.err, &ruleErr) {
+		violation.Resource = ruleErr.resource
+		violation.Rule = ruleErr.rule
+		violation.Actual = ruleErr.actual
+		violation.Bound = ruleErr.bound
+	}
+	return violation
+}
+
+// hasRuleViolation returns true when one of the violations breaks the given
+// rule
+func hasRuleViolation(violations []error, rule string) bool {
+	for _, violation := range violations {
+		var ruleErr ruleError
+		if errors.As(violation, &ruleErr) && ruleErr.rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// violationDetails returns the JSON encoded structured descriptions of the
+// violations joined in the given error
+func violationDetails(err error) (string, error) {
+	violations := []Violation{}
+	for _, e := range flattenErrors(err) {
+		var violationErr violationError
+		if errors.As(e, &violationErr) {
+			violations = append(violations, violationErr.violation())
+			continue
+		}
+		violations = append(violations, Violation{Message: e.Error()})
+	}
+	details, err := json.Marshal(violations)
+	if err != nil {
+		return "", err
+	}
+	return string(details), nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kubewarden/container-resources-policy/resource"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+func TestViolationDetails(t *testing.T) {
+	tests := []struct {
+		name               string
+		container          *corev1.Container
+		settings           Settings
+		expectedViolations []Violation
+	}{
+		{
+			"max limit",
+			newContainerWithCpu("3", "1"),
+			Settings{Cpu: &ResourceConfiguration{MaxLimit: resource.MustParse("2"), DefaultLimit: resource.MustParse("1"), DefaultRequest: resource.MustParse("1")}},
+			[]Violation{{
+				Field:         "spec.containers[0].resources.limits.cpu",
+				Container:     "nginx",
+				ContainerKind: "container",
+				Resource:      "cpu",
+				Rule:          RuleMaxLimit,
+				Actual:        "3",
+				Bound:         "2",
+				Message:       "spec.containers[0].resources.limits.cpu (container 'nginx'): cpu limit '3' exceeds the max allowed value '2'",
+			}},
+		},
+		{
+			"min request",
+			newContainerWithCpu("1", "100m"),
+			Settings{Cpu: &ResourceConfiguration{MaxLimit: resource.MustParse("2"), MinRequest: resource.MustParse("200m"), DefaultLimit: resource.MustParse("1"), DefaultRequest: resource.MustParse("1")}},
+			[]Violation{{
+				Field:         "spec.containers[0].resources.requests.cpu",
+				Container:     "nginx",
+				ContainerKind: "container",
+				Resource:      "cpu",
+				Rule:          RuleMinRequest,
+				Actual:        "100m",
+				Bound:         "200m",
+				Message:       "spec.containers[0].resources.requests.cpu (container 'nginx'): cpu request '100m' is less than the min allowed value '200m'",
+			}},
+		},
+		{
+			"limit request ratio",
+			newContainerWithCpu("1", "300m"),
+			Settings{Cpu: &ResourceConfiguration{MaxLimit: resource.MustParse("2"), MaxLimitRequestRatio: resource.MustParse("2"), DefaultLimit: resource.MustParse("1"), DefaultRequest: resource.MustParse("1")}},
+			[]Violation{{
+				Field:         "spec.containers[0].resources.limits.cpu",
+				Container:     "nginx",
+				ContainerKind: "container",
+				Resource:      "cpu",
+				Rule:          RuleMaxLimitRequestRatio,
+				Actual:        "3.333",
+				Bound:         "2",
+				Message:       "spec.containers[0].resources.limits.cpu (container 'nginx'): cpu limit '1' to request '300m' ratio exceeds the max allowed ratio '2'",
+			}},
+		},
+		{
+			"missing limit",
+			newContainerWithCpu("", "1"),
+			Settings{Cpu: &ResourceConfiguration{IgnoreValues: true}},
+			[]Violation{{
+				Field:         "spec.containers[0].resources.limits.cpu",
+				Container:     "nginx",
+				ContainerKind: "container",
+				Resource:      "cpu",
+				Rule:          RuleRequiredLimit,
+				Message:       "spec.containers[0].resources.limits.cpu (container 'nginx'): container does not have a cpu limit",
+			}},
+		},
+		{
+			"pod total",
+			newContainerWithCpu("3", "1"),
+			Settings{Pod: &PodConfiguration{Resources: map[string]*PodResourceConfiguration{"cpu": {MaxLimit: resource.MustParse("2")}}}},
+			[]Violation{{
+				Field:    "spec",
+				Resource: "cpu",
+				Rule:     RulePodMaxLimit,
+				Actual:   "3",
+				Bound:    "2",
+				Message:  "spec: pod total cpu limit '3' exceeds the max allowed value '2'",
+			}},
+		},
+		{
+			"qos class",
+			newContainerWithCpu("", ""),
+			Settings{Qos: &QosConfiguration{AllowedClasses: []string{QosGuaranteed, QosBurstable}}},
+			[]Violation{{
+				Field:   "spec",
+				Rule:    RuleQosClass,
+				Actual:  QosBestEffort,
+				Bound:   "Guaranteed,Burstable",
+				Message: "spec: pod QoS class BestEffort is not allowed. Allowed classes: Guaranteed, Burstable",
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := "nginx"
+			test.container.Name = &name
+			podSpec := &corev1.PodSpec{Containers: []*corev1.Container{test.container}}
+			_, _, err := validatePodSpec(podSpec, nil, "spec", &test.settings)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			details, err := violationDetails(err)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			violations := []Violation{}
+			if err := json.Unmarshal([]byte(details), &violations); err != nil {
+				t.Fatalf("cannot parse the violation details: %v", err)
+			}
+			if diff := cmp.Diff(test.expectedViolations, violations); diff != "" {
+				t.Errorf("invalid violation details (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestViolationDetailsOfWrappedErrors(t *testing.T) {
+	err := violationError{
+		field:         "spec.containers[0].resources.requests.cpu",
+		container:     "nginx",
+		containerKind: "container",
+		err:           fmt.Errorf("There is an issue after resource requests mutation: %w", newRuleError(RuleMaxRequest, "cpu", "2", "1", "cpu request '2' exceeds the max allowed request value '1'")),
+	}
+	violation := err.violation()
+	if violation.Rule != RuleMaxRequest || violation.Actual != "2" || violation.Bound != "1" {
+		t.Errorf("the details of the wrapped error are missing: %+v", violation)
+	}
+
+	details, detailsErr := violationDetails(errors.Join(err, fmt.Errorf("generic error")))
+	if detailsErr != nil {
+		t.Fatalf("unexpected error: %v", detailsErr)
+	}
+	violations := []Violation{}
+	if err := json.Unmarshal([]byte(details), &violations); err != nil {
+		t.Fatalf("cannot parse the violation details: %v", err)
+	}
+	if len(violations) != 2 || violations[1].Message != "generic error" || violations[1].Rule != "" {
+		t.Errorf("invalid violation details: %s", details)
+	}
+}
+
+func TestViolationDetailsAuditAnnotation(t *testing.T) {
+	for _, mode := range []string{ModeEnforce, ModeWarn, ModeAudit} {
+		t.Run(mode, func(t *testing.T) {
+			rawSettings := fmt.Sprintf(`{"cpu": {"maxLimit": "1m", "defaultRequest": "1m", "defaultLimit": "1m"}, "mode": "%s"}`, mode)
+			response := validateTestRequest(t, "test_data/pod_exceeding_range.json", rawSettings, nil)
+			details, found := response.AuditAnnotations[violationDetailsAuditAnnotation]
+			if !found {
+				t.Fatalf("missing violation details annotation: %v", response.AuditAnnotations)
+			}
+			violations := []Violation{}
+			if err := json.Unmarshal([]byte(details), &violations); err != nil {
+				t.Fatalf("cannot parse the violation details: %v", err)
+			}
+			if len(violations) != 2 {
+				t.Fatalf("expected 2 violations, got %s", details)
+			}
+			for _, violation := range violations {
+				if violation.Rule != RuleMaxLimit || violation.Resource != "cpu" || violation.Bound != "1m" {
+					t.Errorf("invalid violation: %+v", violation)
+				}
+			}
+		})
+	}
+
+	response := validateTestRequest(t, "test_data/pod_within_range.json", `{"cpu": {"maxLimit": "4", "defaultRequest": "1m", "defaultLimit": "1m"}}`, nil)
+	if _, found := response.AuditAnnotations[violationDetailsAuditAnnotation]; found {
+		t.Errorf("unexpected violation details: %v", response.AuditAnnotations)
+	}
+}